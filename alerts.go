@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/alerting"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secrets"
+)
+
+const (
+	ruleAuthFailures        = "auth_failures"
+	ruleHoneytokenUsed      = "honeytoken_used"
+	ruleKeyAnomaly          = "key_anomaly"
+	ruleKeyStoreUnavailable = "key_store_unavailable"
+	ruleEventsDropped       = "events_dropped"
+)
+
+// newAlerter configures alerting from the environment, reading sink
+// credentials from p. It returns nil when no sink is configured.
+func newAlerter(p secrets.Provider) (*alerting.Alerter, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var sinks []alerting.Sink
+	webhookURL, err := lookupSecret(p, "ALERT_WEBHOOK_URL")
+	if err != nil {
+		return nil, err
+	}
+	if webhookURL != "" {
+		sinks = append(sinks, &alerting.Webhook{URL: webhookURL, Client: client})
+	}
+	slackURL, err := lookupSecret(p, "ALERT_SLACK_WEBHOOK_URL")
+	if err != nil {
+		return nil, err
+	}
+	if slackURL != "" {
+		sinks = append(sinks, &alerting.Slack{URL: slackURL, Client: client})
+	}
+	routingKey, err := lookupSecret(p, "ALERT_PAGERDUTY_ROUTING_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if routingKey != "" {
+		sinks = append(sinks, &alerting.PagerDuty{RoutingKey: routingKey, Source: "notely", Client: client})
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+
+	failuresPerMinute := 100
+	if v := os.Getenv("ALERT_AUTH_FAILURES_PER_MINUTE"); v != "" {
+		failuresPerMinute, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("ALERT_AUTH_FAILURES_PER_MINUTE is not a number: %w", err)
+		}
+	}
+
+	rules := []alerting.Rule{
+		{Name: ruleAuthFailures, Severity: alerting.SeverityWarning, Threshold: failuresPerMinute, Window: time.Minute},
+		{Name: ruleHoneytokenUsed, Severity: alerting.SeverityCritical, Threshold: 1, Window: time.Minute},
+		{Name: ruleKeyAnomaly, Severity: alerting.SeverityWarning, Threshold: 1, Window: time.Minute},
+		{Name: ruleKeyStoreUnavailable, Severity: alerting.SeverityError, Threshold: 10, Window: time.Minute},
+		{Name: ruleEventsDropped, Severity: alerting.SeverityWarning, Threshold: 1, Window: time.Minute},
+	}
+	return alerting.New(rules, 15*time.Minute, sinks...), nil
+}
+
+// alertOnEvent feeds auth events into the alerting rules and queues the
+// alerts that fire for AlertQueue to send, so a slow sink can't back up
+// the bus. It is subscribed with SubscribeUnbounded, so it sees every
+// event however far behind it gets, and must stay quick.
+func (cfg *apiConfig) alertOnEvent(_ context.Context, ev authevents.Event) error {
+	var rule, subject, summary string
+	switch p := ev.Payload.(type) {
+	case authevents.Failure:
+		rule = ruleAuthFailures
+		summary = fmt.Sprintf("Auth failure rate over threshold, latest: %s", p.Reason)
+	case authevents.HoneytokenUsed:
+		rule, subject = ruleHoneytokenUsed, p.KeyFingerprint
+		summary = fmt.Sprintf("Honeytoken %s used from %s (%s %s)", p.KeyFingerprint, p.RemoteAddr, p.Method, p.Path)
+	case authevents.Anomaly:
+		rule, subject = ruleKeyAnomaly, p.KeyFingerprint+"/"+p.Kind
+		summary = fmt.Sprintf("Anomalous use of api key %s (%s): %s", p.KeyFingerprint, p.Kind, p.Detail)
+	case authevents.StoreFallback:
+		rule = ruleKeyStoreUnavailable
+		summary = fmt.Sprintf("Key store lookups failing: %s", p.Error)
+	default:
+		return nil
+	}
+
+	cfg.recordAlert(rule, subject, summary)
+	return nil
+}
+
+// recordAlert counts an occurrence of rule and queues the alert if it
+// fires. Alerts that don't fit in the queue are logged and dropped rather
+// than retried through the bus, which would count the event twice.
+func (cfg *apiConfig) recordAlert(rule, subject, summary string) {
+	alert, ok := cfg.Alerts.Record(rule, subject, summary)
+	if !ok {
+		return
+	}
+	if !cfg.AlertQueue.Enqueue(alert) {
+		log.Printf("Dropped alert %s: delivery queue full", alert.DedupKey)
+	}
+}
+
+// watchDroppedEvents checks every interval, until ctx ends, whether any
+// event subscriber has dropped events, and alerts if so.
+func (cfg *apiConfig) watchDroppedEvents(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	seen := map[string]uint64{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			seen = cfg.alertOnDroppedEvents(seen)
+		}
+	}
+}
+
+// alertOnDroppedEvents alerts for each subscriber that has dropped more
+// events than counted in seen, and returns the current counts.
+func (cfg *apiConfig) alertOnDroppedEvents(seen map[string]uint64) map[string]uint64 {
+	dropped := cfg.Events.Dropped()
+	for name, n := range dropped {
+		if n > seen[name] {
+			cfg.recordAlert(ruleEventsDropped, name, fmt.Sprintf("%d auth events dropped for subscriber %s", n-seen[name], name))
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/alerting"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	alerts []alerting.Alert
+}
+
+func (s *recordingSink) Send(_ context.Context, alert alerting.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func TestAlertOnDroppedEvents(t *testing.T) {
+	sink := &recordingSink{}
+	cfg := &apiConfig{
+		Events: authevents.NewBus(),
+		Alerts: alerting.New([]alerting.Rule{{Name: ruleEventsDropped, Severity: alerting.SeverityWarning, Threshold: 1, Window: time.Minute}}, time.Nanosecond, sink),
+	}
+	cfg.AlertQueue = alerting.NewDispatcher(cfg.Alerts, 10, time.Second)
+
+	release := make(chan struct{})
+	cfg.Events.Subscribe("stuck", func(context.Context, authevents.Event) error {
+		<-release
+		return nil
+	})
+
+	seen := cfg.alertOnDroppedEvents(map[string]uint64{})
+	for i := 0; i < 300; i++ {
+		cfg.Events.Publish(authevents.Login{})
+	}
+	seen = cfg.alertOnDroppedEvents(seen)
+	cfg.alertOnDroppedEvents(seen)
+
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := cfg.Events.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.AlertQueue.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one", sink.alerts)
+	}
+	if a := sink.alerts[0]; a.Rule != ruleEventsDropped || a.Subject != "stuck" {
+		t.Errorf("alert = %+v, want %s for stuck", a, ruleEventsDropped)
+	}
+}
+
+func TestDrain_SendsAlertsForQueuedEvents(t *testing.T) {
+	sink := &recordingSink{}
+	cfg := &apiConfig{
+		Events: authevents.NewBus(),
+		Alerts: alerting.New([]alerting.Rule{{Name: ruleHoneytokenUsed, Severity: alerting.SeverityCritical, Threshold: 1, Window: time.Minute}}, time.Minute, sink),
+	}
+	cfg.AlertQueue = alerting.NewDispatcher(cfg.Alerts, 10, time.Second)
+	cfg.Events.SubscribeUnbounded("alerting", cfg.alertOnEvent)
+
+	cfg.Events.Publish(authevents.HoneytokenUsed{KeyFingerprint: "abc"})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cfg.drain(ctx)
+
+	if len(sink.alerts) != 1 || sink.alerts[0].Rule != ruleHoneytokenUsed {
+		t.Errorf("alerts = %+v, want one %s", sink.alerts, ruleHoneytokenUsed)
+	}
+}
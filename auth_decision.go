@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/breaker"
+	"github.com/bootdotdev/learn-cicd-starter/internal/challenge"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// routeScope is a check a route needs beyond a valid key.
+type routeScope struct {
+	scope     string
+	authorize func(identity auth.Identity, ownerID string) error
+	denied    string
+}
+
+// routeScopes are the routes that need a scope, as "METHOD pattern". The
+// handlers check again against the resource they act on.
+var routeScopes = map[string]routeScope{
+	"POST /v1/keys":           {auth.ScopeKeysManage, auth.AuthorizeKeyManagement, "API key isn't allowed to manage keys"},
+	"PUT /v1/keys/{keyID}":    {auth.ScopeKeysManage, auth.AuthorizeKeyManagement, "API key isn't allowed to manage keys"},
+	"DELETE /v1/keys/{keyID}": {auth.ScopeKeysManage, auth.AuthorizeKeyManagement, "API key isn't allowed to manage keys"},
+	"DELETE /v1/users":        {auth.ScopeAccountDelete, auth.AuthorizeAccountDeletion, "API key isn't allowed to delete the user"},
+}
+
+// authDecision is how a request to an authenticated route is treated, and
+// why. middlewareAuth acts on it and the explain endpoint reports it, so
+// the two can't disagree.
+type authDecision struct {
+	Allowed bool
+	// Status, Problem and Message make up the response to a denied
+	// request, with Header set on it. Err is logged. An empty Problem
+	// means a plain error response rather than an auth failure.
+	Status  int
+	Problem string
+	Message string
+	Err     error
+	Header  http.Header
+	// Canceled means the request ended before a decision was reached, so
+	// there is no one to respond to.
+	Canceled bool
+
+	// APIKey, User and Identity are set once they are known, even if the
+	// request is then denied.
+	APIKey   string
+	User     database.User
+	Identity auth.Identity
+	Steps    []explainStep
+
+	release func()
+}
+
+func (d *authDecision) step(stage, outcome, format string, args ...any) {
+	d.Steps = append(d.Steps, explainStep{Stage: stage, Outcome: outcome, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (d *authDecision) deny(status int, problem, message string, err error) authDecision {
+	d.Status, d.Problem, d.Message, d.Err = status, problem, message, err
+	return *d
+}
+
+// Release frees what the decision holds on behalf of the request.
+func (d authDecision) Release() {
+	if d.release != nil {
+		d.release()
+	}
+}
+
+// respond writes the response to a denied request.
+func (d authDecision) respond(cfg *apiConfig, w http.ResponseWriter, r *http.Request) {
+	for name, values := range d.Header {
+		w.Header()[name] = values
+	}
+	if d.Problem == "" {
+		respondWithError(w, d.Status, d.Message, d.Err)
+		return
+	}
+	cfg.respondWithAuthError(w, r, d.Status, d.Problem, d.Message, d.Err)
+}
+
+// String summarizes the decision for logs.
+func (d authDecision) String() string {
+	stages := make([]string, len(d.Steps))
+	for i, s := range d.Steps {
+		stages[i] = s.Stage + " " + s.Outcome
+	}
+	outcome := "allow"
+	if !d.Allowed {
+		outcome = strings.TrimSpace(fmt.Sprintf("deny %d %s", d.Status, d.Problem))
+	}
+	return fmt.Sprintf("%s (%s)", outcome, strings.Join(stages, ", "))
+}
+
+// decideAuth authenticates r, a request for route, and authorizes it for
+// the route. When live is false nothing is changed on the request's
+// behalf, so that the decision can be explained: no events are published,
+// no concurrency slot is taken, challenge responses aren't used up and the
+// key is looked up without going through the resolver or breaker. The
+// caller must Release a live decision.
+func (cfg *apiConfig) decideAuth(r *http.Request, route string, live bool) authDecision {
+	d := authDecision{Header: http.Header{}}
+
+	if cfg.Challenges == nil {
+		d.step("challenge", "skip", "challenges are disabled")
+	} else if client := clientAddr(r.RemoteAddr); !cfg.ChallengeTracker.Required(client) {
+		d.step("challenge", "pass", "client %q hasn't failed often enough to be challenged", client)
+	} else if !live {
+		if r.Header.Get(challenge.ResponseHeader) == "" {
+			d.step("challenge", "fail", "client %q must solve a challenge and sent no response", client)
+			return d.deny(http.StatusUnauthorized, problemChallengeRequired, "Solve the challenge to continue", nil)
+		}
+		d.step("challenge", "note", "client %q must solve a challenge; the response isn't checked here because checking uses it up", client)
+	} else if err := cfg.Challenges.Verify(client, r.Header.Get(challenge.ResponseHeader)); err != nil {
+		d.step("challenge", "fail", "client %q must solve a challenge: %v", client, err)
+		header, cerr := cfg.Challenges.Challenge(client)
+		if cerr != nil {
+			return d.deny(http.StatusInternalServerError, "", "Couldn't issue challenge", cerr)
+		}
+		d.Header.Set(challenge.Header, header)
+		return d.deny(http.StatusUnauthorized, problemChallengeRequired, "Solve the challenge to continue", err)
+	} else {
+		d.step("challenge", "pass", "client %q solved the challenge", client)
+	}
+
+	apiKey, err := auth.GetAPIKey(r.Header, auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders))
+	if cfg.ShadowParse != nil {
+		if m, ok := cfg.ShadowParse.Compare(r.Header, apiKey, err); ok {
+			d.step("shadow", "note", "shadow parsing disagrees (%s): candidate error %q", m.Kind, m.Candidate)
+			if live {
+				cfg.publishShadowMismatch(r, apiKey, m)
+			}
+		}
+	}
+	if err != nil {
+		d.step("parse", "fail", "%v", err)
+		if live {
+			cfg.Events.Publish(authevents.Failure{Reason: err.Error(), RemoteAddr: r.RemoteAddr})
+		}
+		return d.deny(http.StatusUnauthorized, problemInvalidAuthHeader, "Couldn't find api key", err)
+	}
+	d.APIKey = apiKey
+	keyHash := auth.HashKey(apiKey)
+	fingerprint := auth.FingerprintFromHash(keyHash)
+	d.step("parse", "pass", "api key %s, fingerprint %s", auth.Mask(apiKey), fingerprint)
+	if used := legacyBehaviors(r, apiKey); len(used) > 0 {
+		d.step("deprecation", "note", "relies on %s", strings.Join(used, ", "))
+	}
+
+	if !live {
+		d.step("concurrency", "note", "%d requests in flight with this key", cfg.Concurrency.InFlight(fingerprint))
+	} else if release, ok := cfg.Concurrency.Acquire(fingerprint); ok {
+		d.release = release
+	} else {
+		d.step("concurrency", "fail", "too many requests in flight with this key")
+		d.Header.Set("Retry-After", "1")
+		return d.deny(http.StatusTooManyRequests, problemTooManyConcurrent, "Too many concurrent requests for this api key", nil)
+	}
+
+	if cfg.Honeytokens.Contains(apiKey) {
+		d.step("honeytoken", "note", "decoy key: alerted on and answered with canned responses, never by the real handlers")
+		if live {
+			cfg.Events.Publish(authevents.HoneytokenUsed{
+				KeyFingerprint: fingerprint,
+				RemoteAddr:     r.RemoteAddr,
+				Method:         r.Method,
+				Path:           r.URL.Path,
+				UserAgent:      r.UserAgent(),
+			})
+		}
+		d.User = cfg.honeytokenUser(fingerprint)
+		d.Identity = userIdentity(d.User, fingerprint, nil)
+		// A decoy passes for its user's original key, so GET /v1/users
+		// shows the decoy key like it would the real one.
+		d.Identity.Attributes = map[string]string{auth.AttrHoneytoken: "true", auth.AttrPrimaryKey: "true"}
+		d.Allowed = true
+		return d
+	}
+
+	var rec keyRecord
+	stale := false
+	if live {
+		rec, err = cfg.Users.Resolve(r.Context(), apiKey)
+		if err == nil {
+			d.step("store", "pass", "resolved")
+		}
+	} else {
+		rec, err = cfg.peekKey(r, &d, apiKey)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, context.Canceled) {
+		rec, err = cfg.keyStoreFallback(r, &d, keyHash, err, live)
+		stale = err == nil
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// The caller hung up. Nothing is wrong with the key store and
+		// there's no one to answer.
+		d.step("store", "fail", "request canceled")
+		d.Canceled = true
+		return d.deny(0, "", "", err)
+	case errors.Is(err, breaker.ErrOpen), errors.Is(err, context.DeadlineExceeded):
+		d.Header.Set("Retry-After", "30")
+		return d.deny(http.StatusServiceUnavailable, problemKeyStoreUnavailable, "Key store unavailable", err)
+	case errors.Is(err, sql.ErrNoRows):
+		d.step("store", "fail", "no such key")
+		if live {
+			cfg.Events.Publish(authevents.Failure{Reason: "unknown api key", KeyFingerprint: fingerprint, RemoteAddr: r.RemoteAddr})
+		}
+		return d.deny(http.StatusNotFound, problemUnknownAPIKey, "Couldn't get user", fmt.Errorf("get user for key %s: %w", auth.Mask(apiKey), err))
+	case err != nil:
+		return d.deny(http.StatusInternalServerError, problemKeyLookupFailed, "Couldn't get user", fmt.Errorf("get user for key %s: %w", auth.Mask(apiKey), err))
+	}
+
+	d.User = rec.User
+	d.Identity = userIdentity(rec.User, fingerprint, rec.Scopes)
+	d.Identity.Attributes = map[string]string{}
+	if stale {
+		d.Identity.Attributes[auth.AttrStale] = "true"
+	}
+	if rec.KeyID == "" {
+		d.Identity.Attributes[auth.AttrPrimaryKey] = "true"
+	}
+
+	if rec.Revoked {
+		d.step("revocation", "fail", "key is revoked")
+		if live {
+			cfg.Events.Publish(authevents.Failure{Reason: "revoked api key", KeyFingerprint: fingerprint, RemoteAddr: r.RemoteAddr})
+		}
+		return d.deny(http.StatusUnauthorized, problemRevokedAPIKey, "API key has been revoked", nil)
+	}
+	d.step("revocation", "pass", "key is active")
+
+	scopes := auth.FormatScopes(d.Identity.Scopes)
+	if rs, ok := routeScopes[r.Method+" "+route]; ok {
+		if err := rs.authorize(d.Identity, rec.User.ID); err != nil {
+			d.step("scopes", "fail", "route needs %s; key has [%s]: %v", rs.scope, scopes, err)
+			return d.deny(http.StatusForbidden, problemInsufficientScope, rs.denied, err)
+		}
+		d.step("scopes", "pass", "route needs %s; key has [%s]", rs.scope, scopes)
+	} else {
+		d.step("scopes", "pass", "route needs no scope; key has [%s]", scopes)
+	}
+
+	if live && rec.KeyID != "" && !stale {
+		cfg.touchAPIKey(r, rec.KeyID)
+	}
+	d.Allowed = true
+	return d
+}
+
+// peekKey looks apiKey up for an explanation. It reads the cache and
+// database directly rather than through the resolver and breaker, so it
+// neither fills the cache nor counts towards tripping.
+func (cfg *apiConfig) peekKey(r *http.Request, d *authDecision, apiKey string) (keyRecord, error) {
+	if rec, age, ok := cfg.Users.Peek(auth.HashKey(apiKey)); ok && age < cfg.Users.TTL() {
+		d.step("store", "pass", "answered from cache, fetched %s ago", age.Round(time.Second))
+		return rec, nil
+	}
+	state := cfg.KeyStore.State()
+	if state == breaker.Open {
+		return keyRecord{}, breaker.ErrOpen
+	}
+	rec, err := cfg.fetchKey(r.Context(), apiKey)
+	if err == nil {
+		d.step("store", "pass", "answered by the database, breaker %s", state)
+	}
+	return rec, err
+}
+
+// keyStoreFallback applies the route's failure policy after a key store
+// error. Failing open reuses the last successful lookup for the key, if it
+// is recent enough; otherwise lookupErr is returned unchanged. A live
+// decision is published either way.
+func (cfg *apiConfig) keyStoreFallback(r *http.Request, d *authDecision, keyHash string, lookupErr error, live bool) (keyRecord, error) {
+	class := auth.RouteClassOf(r)
+	policy := cfg.FailurePolicies.For(class)
+
+	decision := auth.FailClosed
+	rec := keyRecord{}
+	if policy == auth.FailOpen {
+		var ok bool
+		if live {
+			rec, ok = cfg.Users.Stale(keyHash)
+		} else {
+			var age time.Duration
+			rec, age, ok = cfg.Users.Peek(keyHash)
+			ok = ok && age <= cfg.Users.MaxStale()
+		}
+		if ok {
+			decision = auth.FailOpen
+		}
+	}
+
+	if live {
+		cfg.Events.Publish(authevents.StoreFallback{
+			KeyFingerprint: auth.FingerprintFromHash(keyHash),
+			RouteClass:     string(class),
+			Decision:       decision.String(),
+			Error:          lookupErr.Error(),
+		})
+	}
+	if decision == auth.FailOpen {
+		d.step("store", "note", "key store failed (%v); %s routes fail open and a cached record was used", lookupErr, class)
+		return rec, nil
+	}
+	d.step("store", "fail", "key store failed (%v); %s routes fail %s", lookupErr, class, policy)
+	return keyRecord{}, lookupErr
+}
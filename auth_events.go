@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+// logAuthEvent writes security-relevant auth events to the server log.
+// Routine logins and failures are left out; they are already visible in
+// request and error logs.
+func logAuthEvent(_ context.Context, ev authevents.Event) error {
+	switch p := ev.Payload.(type) {
+	case authevents.HoneytokenUsed:
+		log.Printf("ALERT: honeytoken %s used from %s (%s %s, user agent %q)",
+			p.KeyFingerprint, p.RemoteAddr, p.Method, p.Path, p.UserAgent)
+	case authevents.KeyRevoked:
+		log.Printf("Revoked api key %s: %s", p.KeyFingerprint, p.Reason)
+	case authevents.StoreFallback:
+		log.Printf("Key store unavailable, failed %s for %s request with key %s: %s",
+			p.Decision, p.RouteClass, p.KeyFingerprint, p.Error)
+	case authevents.Anomaly:
+		log.Printf("ALERT: anomalous use of api key %s (%s): %s", p.KeyFingerprint, p.Kind, p.Detail)
+	case authevents.BulkRevocation:
+		verb := "Revoked"
+		if p.DryRun {
+			verb = "Dry run: would revoke"
+		}
+		log.Printf("%s %d of %d api keys matching %s: %s", verb, p.Revoked, p.Matched, p.Filter, p.Reason)
+	case authevents.ShadowMismatch:
+		log.Printf("Shadow %s mismatch (%s) for key %s from %s: primary %q, candidate %q",
+			p.Check, p.Kind, p.KeyFingerprint, p.RemoteAddr, p.Primary, p.Candidate)
+	case authevents.IdentityErased:
+		log.Printf("Erased identity %s: %d notes, %d api keys", p.SubjectHash, p.NotesDeleted, p.APIKeysDeleted)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import "github.com/bootdotdev/learn-cicd-starter/internal/i18n"
+
+// authMessages translates the detail of auth failure responses. Problem
+// types stay the same in every language; only the human-readable text
+// varies.
+var authMessages = i18n.Catalog{
+	"de": {
+		"Couldn't find api key":                         "API-Schlüssel nicht gefunden",
+		"Too many concurrent requests for this api key": "Zu viele gleichzeitige Anfragen für diesen API-Schlüssel",
+		"Key store unavailable":                         "Schlüsselspeicher nicht verfügbar",
+		"Couldn't get user":                             "Benutzer konnte nicht abgerufen werden",
+		"API key has been revoked":                      "Der API-Schlüssel wurde widerrufen",
+		"Couldn't verify body signature":                "Signatur des Anfragetexts konnte nicht überprüft werden",
+		"Body signature mismatch":                       "Signatur des Anfragetexts stimmt nicht überein",
+		"Couldn't verify report signature":              "Signatur des Berichts konnte nicht überprüft werden",
+		"Solve the challenge to continue":               "Lösen Sie die Aufgabe, um fortzufahren",
+		"Admin access required":                         "Administratorzugriff erforderlich",
+		"API key isn't allowed to manage keys":          "Der API-Schlüssel darf keine Schlüssel verwalten",
+		"Can't grant a scope the api key doesn't have":  "Ein Bereich, den der API-Schlüssel nicht hat, kann nicht vergeben werden",
+	},
+	"es": {
+		"Couldn't find api key":                         "No se encontró la clave de API",
+		"Too many concurrent requests for this api key": "Demasiadas solicitudes simultáneas para esta clave de API",
+		"Key store unavailable":                         "El almacén de claves no está disponible",
+		"Couldn't get user":                             "No se pudo obtener el usuario",
+		"API key has been revoked":                      "La clave de API ha sido revocada",
+		"Couldn't verify body signature":                "No se pudo verificar la firma del cuerpo",
+		"Body signature mismatch":                       "La firma del cuerpo no coincide",
+		"Couldn't verify report signature":              "No se pudo verificar la firma del informe",
+		"Solve the challenge to continue":               "Resuelva el desafío para continuar",
+		"Admin access required":                         "Se requiere acceso de administrador",
+		"API key isn't allowed to manage keys":          "La clave de API no puede gestionar claves",
+		"Can't grant a scope the api key doesn't have":  "No se puede conceder un ámbito que la clave de API no tiene",
+	},
+	"fr": {
+		"Couldn't find api key":                         "Clé d'API introuvable",
+		"Too many concurrent requests for this api key": "Trop de requêtes simultanées pour cette clé d'API",
+		"Key store unavailable":                         "Magasin de clés indisponible",
+		"Couldn't get user":                             "Impossible de récupérer l'utilisateur",
+		"API key has been revoked":                      "La clé d'API a été révoquée",
+		"Couldn't verify body signature":                "Impossible de vérifier la signature du corps",
+		"Body signature mismatch":                       "La signature du corps ne correspond pas",
+		"Couldn't verify report signature":              "Impossible de vérifier la signature du rapport",
+		"Solve the challenge to continue":               "Résolvez le défi pour continuer",
+		"Admin access required":                         "Accès administrateur requis",
+		"API key isn't allowed to manage keys":          "La clé d'API n'est pas autorisée à gérer les clés",
+		"Can't grant a scope the api key doesn't have":  "Impossible d'accorder une portée que la clé d'API ne possède pas",
+	},
+}
+
+var authMessageLanguages = authMessages.Languages()
@@ -0,0 +1,169 @@
+// Package authtest helps services that call the Notely API test their
+// auth integration without a real server or database: deterministic keys,
+// an in-memory key store, a fake server that authenticates like the real
+// one, and request builders.
+package authtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+// Key returns a well-formed API key derived from seed. The same seed always
+// yields the same key, so fixtures can be written down once.
+func Key(seed string) string {
+	secret := sha256.Sum256([]byte(seed))
+	return auth.FormatAPIKey(secret[:])
+}
+
+// BadChecksumKey returns Key(seed) with its checksum corrupted. The server
+// rejects it before any store lookup.
+func BadChecksumKey(seed string) string {
+	key := []byte(Key(seed))
+	last := len(key) - 1
+	if key[last] == '0' {
+		key[last] = '1'
+	} else {
+		key[last] = '0'
+	}
+	return string(key)
+}
+
+var (
+	ErrUnknownKey = errors.New("authtest: unknown api key")
+	ErrRevokedKey = errors.New("authtest: api key has been revoked")
+)
+
+// User is the record a key resolves to.
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// KeyStore is an in-memory stand-in for the server's key store. It is safe
+// for concurrent use; the zero value is empty and ready.
+type KeyStore struct {
+	mu      sync.Mutex
+	users   map[string]User
+	revoked map[string]bool
+}
+
+// Add makes key authenticate as user.
+func (s *KeyStore) Add(key string, user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.users == nil {
+		s.users = map[string]User{}
+	}
+	s.users[key] = user
+	delete(s.revoked, key)
+}
+
+// Revoke makes key fail authentication as revoked.
+func (s *KeyStore) Revoke(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.revoked == nil {
+		s.revoked = map[string]bool{}
+	}
+	s.revoked[key] = true
+}
+
+// Lookup returns the user key authenticates as, ErrUnknownKey or
+// ErrRevokedKey.
+func (s *KeyStore) Lookup(key string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[key]
+	if !ok {
+		return User{}, ErrUnknownKey
+	}
+	if s.revoked[key] {
+		return User{}, ErrRevokedKey
+	}
+	return user, nil
+}
+
+// Problem types the fake server responds with, matching the real API.
+const (
+	ProblemInvalidAuthHeader = "urn:notely:problem:invalid-authorization-header"
+	ProblemUnknownAPIKey     = "urn:notely:problem:unknown-api-key"
+	ProblemRevokedAPIKey     = "urn:notely:problem:revoked-api-key"
+)
+
+// NewServer starts a fake API server that authenticates every request
+// against store the way the real server does and answers GET /v1/users
+// with the caller's user. Other authenticated requests get 404. The server
+// is closed when the test ends.
+func NewServer(t testing.TB, store *KeyStore) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := auth.GetAPIKey(r.Header, auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders))
+		if err != nil {
+			writeProblem(w, http.StatusUnauthorized, ProblemInvalidAuthHeader, err)
+			return
+		}
+		user, err := store.Lookup(key)
+		switch {
+		case errors.Is(err, ErrUnknownKey):
+			writeProblem(w, http.StatusNotFound, ProblemUnknownAPIKey, err)
+			return
+		case errors.Is(err, ErrRevokedKey):
+			writeProblem(w, http.StatusUnauthorized, ProblemRevokedAPIKey, err)
+			return
+		}
+
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/users" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(user)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeProblem(w http.ResponseWriter, code int, problemType string, err error) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"type":   problemType,
+		"title":  http.StatusText(code),
+		"status": code,
+		"detail": err.Error(),
+	})
+}
+
+// NewRequest returns a request authenticated with key, for passing to a
+// handler directly.
+func NewRequest(method, target, key string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	r.Header.Set("Authorization", "ApiKey "+key)
+	return r
+}
+
+// SignRequest sets the body signature header the server requires on writes
+// when it has a body signing secret. The body is read and replaced.
+func SignRequest(t testing.TB, r *http.Request, secret []byte) {
+	t.Helper()
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("authtest: read body: %v", err)
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.Header.Set(auth.BodySignatureHeader, auth.SignBody(body, secret))
+}
@@ -0,0 +1,106 @@
+package authtest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/client"
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+func TestKey(t *testing.T) {
+	if Key("alice") != Key("alice") {
+		t.Errorf("Key() is not deterministic")
+	}
+	if Key("alice") == Key("bob") {
+		t.Errorf("Key() returned the same key for different seeds")
+	}
+	if err := auth.VerifyKeyChecksum(Key("alice")); err != nil {
+		t.Errorf("VerifyKeyChecksum(Key()) error = %v", err)
+	}
+	if err := auth.VerifyKeyChecksum(BadChecksumKey("alice")); err == nil {
+		t.Errorf("VerifyKeyChecksum(BadChecksumKey()) succeeded")
+	}
+}
+
+func TestServer(t *testing.T) {
+	store := &KeyStore{}
+	store.Add(Key("alice"), User{ID: "user-1", Name: "alice"})
+	store.Add(Key("revoked"), User{ID: "user-2", Name: "revoked"})
+	store.Revoke(Key("revoked"))
+	srv := NewServer(t, store)
+
+	tests := map[string]struct {
+		key         string
+		wantStatus  int
+		wantProblem string
+	}{
+		"valid key":    {key: Key("alice"), wantStatus: http.StatusOK},
+		"unknown key":  {key: Key("mallory"), wantStatus: http.StatusNotFound, wantProblem: ProblemUnknownAPIKey},
+		"revoked key":  {key: Key("revoked"), wantStatus: http.StatusUnauthorized, wantProblem: ProblemRevokedAPIKey},
+		"bad checksum": {key: BadChecksumKey("alice"), wantStatus: http.StatusUnauthorized, wantProblem: ProblemInvalidAuthHeader},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/users", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "ApiKey "+tc.key)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+			var body map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if tc.wantProblem != "" && body["type"] != tc.wantProblem {
+				t.Errorf("problem type = %v, want %v", body["type"], tc.wantProblem)
+			}
+			if tc.wantProblem == "" && body["id"] != "user-1" {
+				t.Errorf("user = %v", body)
+			}
+		})
+	}
+}
+
+func TestServer_WithClient(t *testing.T) {
+	store := &KeyStore{}
+	store.Add(Key("alice"), User{ID: "user-1", Name: "alice"})
+	store.Revoke(Key("alice"))
+	srv := NewServer(t, store)
+
+	_, err := client.NewClient(Key("alice")).Get(srv.URL + "/v1/users")
+	var authErr *client.AuthError
+	if !errors.As(err, &authErr) || authErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Get() error = %v, want 401 AuthError", err)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	secret := []byte("s3cret")
+	r := NewRequest(http.MethodPost, "/v1/notes", Key("alice"), strings.NewReader(`{"note":"hi"}`))
+	SignRequest(t, r, secret)
+
+	if got := r.Header.Get("Authorization"); got != "ApiKey "+Key("alice") {
+		t.Errorf("Authorization = %q", got)
+	}
+	body, err := auth.VerifyBody(r.Body, secret, r.Header.Get(auth.BodySignatureHeader))
+	if err != nil {
+		t.Fatalf("VerifyBody() error = %v", err)
+	}
+	if got, err := io.ReadAll(body); err != nil || string(got) != `{"note":"hi"}` {
+		t.Errorf("body = %q, %v", got, err)
+	}
+}
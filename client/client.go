@@ -0,0 +1,183 @@
+// Package client provides an http.RoundTripper for calling the Notely API:
+// it attaches the API key, retries rate-limited requests with backoff, and
+// turns authentication failures into typed errors.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBaseDelay  = 500 * time.Millisecond
+	defaultMaxDelay   = 30 * time.Second
+	maxErrorBody      = 4 << 10
+)
+
+// AuthError is returned when the server rejects the request's credentials.
+type AuthError struct {
+	StatusCode int
+	Message    string
+	// Type is the problem type URI, e.g.
+	// "urn:notely:problem:revoked-api-key", when the server sent an
+	// application/problem+json body. It is empty for the legacy
+	// {"error": ...} shape.
+	Type string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("notely: authentication failed (%d): %s", e.StatusCode, e.Message)
+}
+
+// Transport is an http.RoundTripper that authenticates every request with
+// APIKey. Requests answered with 429 or 503 are retried up to MaxRetries
+// times, waiting for the server's Retry-After when given and a jittered
+// exponential backoff otherwise. Requests whose body can't be replayed
+// (no GetBody) are not retried.
+type Transport struct {
+	APIKey string
+	// Base performs the requests. http.DefaultTransport is used when nil.
+	Base http.RoundTripper
+	// MaxRetries defaults to 3. Set a negative value to disable retries.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the backoff; they default to 500ms and 30s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// NewClient returns an http.Client that authenticates with apiKey.
+func NewClient(apiKey string) *http.Client {
+	return &http.Client{Transport: &Transport{APIKey: apiKey}}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxRetries := t.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq, err := t.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.base().RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return nil, authError(resp)
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		default:
+			return resp, nil
+		}
+
+		if attempt >= maxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		delay := t.retryDelay(resp, attempt)
+		drain(resp)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// prepare clones req with the credential attached, rewinding the body for
+// retries.
+func (t *Transport) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	clone.Header.Set("Authorization", "ApiKey "+t.APIKey)
+	if attempt > 0 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+func (t *Transport) retryDelay(resp *http.Response, attempt int) time.Duration {
+	maxDelay := t.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		return min(d, maxDelay)
+	}
+
+	baseDelay := t.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = defaultBaseDelay
+	}
+	backoff := min(baseDelay<<attempt, maxDelay)
+	// Full jitter: spread retries from many clients across the window.
+	return time.Duration(rand.Int64N(int64(backoff) + 1)) // #nosec G404 -- jitter doesn't need a CSPRNG
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// authError decodes a rejection, either as RFC 9457 problem details or in
+// the legacy {"error": ...} shape the server writes when
+// LegacyErrorResponses is set.
+func authError(resp *http.Response) error {
+	defer resp.Body.Close()
+	authErr := &AuthError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+
+	var body struct {
+		Error  string `json:"error"`
+		Type   string `json:"type"`
+		Detail string `json:"detail"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body); err != nil {
+		return authErr
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "application/problem+json" {
+		authErr.Type = body.Type
+		if body.Detail != "" {
+			authErr.Message = body.Detail
+		}
+	} else if body.Error != "" {
+		authErr.Message = body.Error
+	}
+	return authErr
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport_AttachesAPIKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "ApiKey test-key" {
+			t.Errorf("Authorization = %q, want %q", got, "ApiKey test-key")
+		}
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient("test-key").Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransport_RetriesRateLimited(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"note":"hi"}` {
+			t.Errorf("attempt %d body = %q", attempts, body)
+		}
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: &Transport{APIKey: "k"}}
+	resp, err := c.Post(srv.URL, "application/json", strings.NewReader(`{"note":"hi"}`))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestTransport_GivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := &http.Client{Transport: &Transport{APIKey: "k", MaxRetries: 2, BaseDelay: time.Millisecond}}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("StatusCode = %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestTransport_AuthError(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        AuthError
+	}{
+		{
+			name:        "problem details",
+			contentType: "application/problem+json",
+			body:        `{"type":"urn:notely:problem:revoked-api-key","title":"Unauthorized","status":401,"detail":"API key has been revoked"}`,
+			want:        AuthError{StatusCode: http.StatusUnauthorized, Message: "API key has been revoked", Type: "urn:notely:problem:revoked-api-key"},
+		},
+		{
+			name:        "legacy",
+			contentType: "application/json",
+			body:        `{"error":"API key has been revoked"}`,
+			want:        AuthError{StatusCode: http.StatusUnauthorized, Message: "API key has been revoked"},
+		},
+		{
+			name:        "no body",
+			contentType: "text/plain",
+			want:        AuthError{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			_, err := NewClient("k").Get(srv.URL)
+
+			var authErr *AuthError
+			if !errors.As(err, &authErr) {
+				t.Fatalf("Get() error = %v, want *AuthError", err)
+			}
+			if *authErr != tt.want {
+				t.Errorf("AuthError = %+v, want %+v", *authErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"5", 5 * time.Second, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
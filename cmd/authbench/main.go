@@ -0,0 +1,238 @@
+// Command authbench drives the auth path (header parsing, the key cache,
+// the circuit-breaker-guarded store and the scope check) at a configurable
+// request rate and key cardinality, and reports latency percentiles and
+// allocations per request. Given -slo-p99 or -max-allocs it exits non-zero
+// when they are exceeded, so it can gate a release in CI.
+//
+// It runs the same internal packages the server's auth middleware is built
+// from, in process, against an in-memory store with simulated latency. It
+// doesn't include HTTP serving or the database driver, so numbers are for
+// comparing builds, not for capacity planning.
+//
+//	go run ./cmd/authbench -rps 20000 -keys 10000 -duration 10s -slo-p99 1ms
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/breaker"
+	"github.com/bootdotdev/learn-cicd-starter/internal/limit"
+)
+
+type config struct {
+	rps          int
+	keys         int
+	duration     time.Duration
+	workers      int
+	storeLatency time.Duration
+	cacheTTL     time.Duration
+	warm         bool
+	sloP99       time.Duration
+	maxAllocs    float64
+}
+
+// record is what the benchmark store resolves a key to.
+type record struct {
+	ownerID string
+	scopes  []string
+}
+
+// result summarises a run.
+type result struct {
+	requests int
+	failures int
+	elapsed  time.Duration
+	p50, p99 time.Duration
+	max      time.Duration
+	allocs   float64
+	bytes    float64
+}
+
+func main() {
+	cfg := config{}
+	flag.IntVar(&cfg.rps, "rps", 0, "target requests per second; 0 runs as fast as possible")
+	flag.IntVar(&cfg.keys, "keys", 1000, "number of distinct API keys")
+	flag.DurationVar(&cfg.duration, "duration", 5*time.Second, "how long to run")
+	flag.IntVar(&cfg.workers, "workers", runtime.GOMAXPROCS(0), "concurrent requests")
+	flag.DurationVar(&cfg.storeLatency, "store-latency", time.Millisecond, "simulated latency of a key store lookup")
+	flag.DurationVar(&cfg.cacheTTL, "cache-ttl", 30*time.Second, "key cache TTL")
+	flag.BoolVar(&cfg.warm, "warm", true, "resolve every key once before measuring, so the run starts with a full cache")
+	flag.DurationVar(&cfg.sloP99, "slo-p99", 0, "fail if p99 latency exceeds this; 0 disables")
+	flag.Float64Var(&cfg.maxAllocs, "max-allocs", 0, "fail if allocations per request exceed this; 0 disables")
+	flag.Parse()
+
+	if cfg.keys <= 0 || cfg.workers <= 0 || cfg.duration <= 0 {
+		fmt.Fprintln(os.Stderr, "authbench: -keys, -workers and -duration must be positive")
+		os.Exit(2)
+	}
+
+	res := run(cfg)
+	fmt.Printf("requests   %d (%d failed) in %s, %.0f/s\n", res.requests, res.failures, res.elapsed.Round(time.Millisecond), float64(res.requests)/res.elapsed.Seconds())
+	fmt.Printf("latency    p50 %s  p99 %s  max %s\n", res.p50, res.p99, res.max)
+	fmt.Printf("allocs     %.1f/request, %.0f B/request\n", res.allocs, res.bytes)
+
+	failed := false
+	if cfg.sloP99 > 0 && res.p99 > cfg.sloP99 {
+		fmt.Printf("FAIL: p99 %s exceeds %s\n", res.p99, cfg.sloP99)
+		failed = true
+	}
+	if cfg.maxAllocs > 0 && res.allocs > cfg.maxAllocs {
+		fmt.Printf("FAIL: %.1f allocs/request exceeds %.1f\n", res.allocs, cfg.maxAllocs)
+		failed = true
+	}
+	if res.failures > 0 {
+		fmt.Printf("FAIL: %d requests were rejected\n", res.failures)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run authenticates requests with cfg.keys distinct keys, chosen round
+// robin, until cfg.duration has passed.
+func run(cfg config) result {
+	headers := make([]http.Header, cfg.keys)
+	store := make(map[string]record, cfg.keys)
+	for i := range headers {
+		secret := sha256.Sum256([]byte(strconv.Itoa(i)))
+		key := auth.FormatAPIKey(secret[:])
+		headers[i] = http.Header{"Authorization": {"ApiKey " + key}}
+		store[key] = record{ownerID: strconv.Itoa(i), scopes: auth.KnownScopes}
+	}
+
+	keyStore := breaker.New(5, 30*time.Second, func(err error) bool { return !errors.Is(err, errUnknownKey) })
+	lookup := func(ctx context.Context, apiKey string) (record, error) {
+		var rec record
+		err := keyStore.Do(func() error {
+			time.Sleep(cfg.storeLatency)
+			var ok bool
+			if rec, ok = store[apiKey]; !ok {
+				return errUnknownKey
+			}
+			return nil
+		})
+		return rec, err
+	}
+	resolver := auth.NewResolver(lookup, cfg.cacheTTL, 15*time.Minute)
+	// Sized so that it never rejects: the limiter's cost is measured, not
+	// its policy.
+	concurrency := limit.NewConcurrency(cfg.workers)
+	if cfg.warm {
+		for _, h := range headers {
+			_ = authenticate(context.Background(), resolver, concurrency, h)
+		}
+	}
+
+	jobs := make(chan int, cfg.workers)
+	latencies := make([][]time.Duration, cfg.workers)
+	failures := make([]int, cfg.workers)
+	var wg sync.WaitGroup
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	for w := 0; w < cfg.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ctx := context.Background()
+			for i := range jobs {
+				t := time.Now()
+				if err := authenticate(ctx, resolver, concurrency, headers[i]); err != nil {
+					failures[w]++
+				}
+				latencies[w] = append(latencies[w], time.Since(t))
+			}
+		}(w)
+	}
+	feed(jobs, cfg, start)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	var all []time.Duration
+	res := result{elapsed: elapsed}
+	for w := range latencies {
+		all = append(all, latencies[w]...)
+		res.failures += failures[w]
+	}
+	res.requests = len(all)
+	if res.requests == 0 {
+		return res
+	}
+	slices.Sort(all)
+	res.p50 = all[len(all)*50/100]
+	res.p99 = all[len(all)*99/100]
+	res.max = all[len(all)-1]
+	// The latency slices grow during the run and are counted here too; at
+	// a few allocations per doubling they don't move the per-request figure.
+	res.allocs = float64(after.Mallocs-before.Mallocs) / float64(res.requests)
+	res.bytes = float64(after.TotalAlloc-before.TotalAlloc) / float64(res.requests)
+	return res
+}
+
+var errUnknownKey = errors.New("unknown api key")
+
+// authenticate is the auth path for one request: parse the header, hold a
+// concurrency slot for the key, resolve it through the cache and store,
+// and check it may manage its own keys.
+func authenticate(ctx context.Context, resolver *auth.Resolver[record], concurrency *limit.Concurrency, h http.Header) error {
+	apiKey, err := auth.GetAPIKey(h, auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders))
+	if err != nil {
+		return err
+	}
+	fingerprint := auth.Fingerprint(apiKey)
+	release, ok := concurrency.Acquire(fingerprint)
+	if !ok {
+		return errors.New("too many concurrent requests")
+	}
+	defer release()
+
+	rec, err := resolver.Resolve(ctx, apiKey)
+	if err != nil {
+		return err
+	}
+	identity := auth.Identity{ID: rec.ownerID, Type: auth.PrincipalUser, CredentialID: fingerprint, Scopes: rec.scopes}
+	return auth.AuthorizeKeyManagement(identity, rec.ownerID)
+}
+
+// feed sends key indexes to jobs, paced to cfg.rps if set, until
+// cfg.duration after start, then closes it.
+func feed(jobs chan<- int, cfg config, start time.Time) {
+	defer close(jobs)
+	deadline := start.Add(cfg.duration)
+	var interval time.Duration
+	if cfg.rps > 0 {
+		interval = time.Second / time.Duration(cfg.rps)
+	}
+	next := start
+	for i := 0; ; i++ {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return
+		}
+		if interval > 0 {
+			if d := next.Sub(now); d > 0 {
+				time.Sleep(d)
+			}
+			next = next.Add(interval)
+		}
+		jobs <- i % cfg.keys
+	}
+}
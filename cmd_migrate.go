@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/migrate"
+)
+
+//go:embed sql/schema/*.sql
+var schemaFiles embed.FS
+
+const migrateUsage = "usage: notely migrate up|down|status"
+
+// runMigrate implements "notely migrate", applying the embedded schema
+// migrations to DATABASE_URL.
+func runMigrate(args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	dbURL, err := lookupSecret(newSecretProvider(), "DATABASE_URL")
+	if err != nil {
+		return err
+	}
+	if dbURL == "" {
+		return errors.New("DATABASE_URL environment variable is not set")
+	}
+	schema, err := fs.Sub(schemaFiles, "sql/schema")
+	if err != nil {
+		return err
+	}
+	migrations, err := migrate.Parse(schema)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("libsql", dbURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	m := migrate.New(db, migrations)
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %s\n", mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+	case "down":
+		mig, err := m.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rolled back %s\n", mig.Name)
+		return nil
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%-8s %s\n", state, s.Name)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
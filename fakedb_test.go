@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// fakeDB is an in-memory stand-in for the database, for handler tests. It
+// runs the sqlc queries in internal/database by name, so it needs a case
+// here for every query the code under test makes. Transactions aren't
+// isolated; Commit and Rollback do nothing.
+type fakeDB struct {
+	mu       sync.Mutex
+	users    []database.User
+	notes    []database.Note
+	apiKeys  []database.ApiKey
+	oneTime  []database.OneTimeToken
+	failWith error
+}
+
+var (
+	fakeDBs   sync.Map
+	fakeDBSeq atomic.Int64
+)
+
+func init() {
+	sql.Register("fakedb", fakeDriver{})
+}
+
+// openFakeDB returns a new empty fakeDB and a *sql.DB connected to it.
+func openFakeDB() (*fakeDB, *sql.DB) {
+	db := &fakeDB{}
+	name := fmt.Sprintf("fakedb-%d", fakeDBSeq.Add(1))
+	fakeDBs.Store(name, db)
+	conn, err := sql.Open("fakedb", name)
+	if err != nil {
+		panic(err)
+	}
+	return db, conn
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: no database %q", name)
+	}
+	return fakeConn{db.(*fakeDB)}, nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements aren't supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+var queryName = regexp.MustCompile(`-- name: (\w+)`)
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	n, err := c.db.exec(name(query), values(args))
+	return driver.RowsAffected(n), err
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows, err := c.db.query(name(query), values(args))
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+func name(query string) string {
+	if m := queryName.FindStringSubmatch(query); m != nil {
+		return m[1]
+	}
+	return query
+}
+
+func values(args []driver.NamedValue) []any {
+	vs := make([]any, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
+
+func str(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func nullStr(v any) sql.NullString {
+	s, ok := v.(string)
+	return sql.NullString{String: s, Valid: ok}
+}
+
+func nullValue(s sql.NullString) driver.Value {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
+
+func userRow(u database.User) []driver.Value {
+	return []driver.Value{u.ID, u.CreatedAt, u.UpdatedAt, u.Name, u.ApiKey, nullValue(u.ApiKeyRevokedAt)}
+}
+
+func apiKeyRow(k database.ApiKey) []driver.Value {
+	return []driver.Value{k.ID, k.CreatedAt, k.UpdatedAt, k.UserID, k.Name, k.KeyHash, k.KeyHint,
+		nullValue(k.LastUsedAt), nullValue(k.RevokedAt), k.Scopes, k.CreatedVia, nullValue(k.CreatedBy)}
+}
+
+func noteRow(n database.Note) []driver.Value {
+	return []driver.Value{n.ID, n.CreatedAt, n.UpdatedAt, n.Note, n.UserID}
+}
+
+func (db *fakeDB) exec(query string, args []any) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.failWith != nil {
+		return 0, db.failWith
+	}
+
+	switch query {
+	case "CreateUser":
+		db.users = append(db.users, database.User{ID: str(args[0]), CreatedAt: str(args[1]), UpdatedAt: str(args[2]), Name: str(args[3]), ApiKey: str(args[4])})
+		return 1, nil
+	case "DeleteUser":
+		return deleteWhere(&db.users, func(u database.User) bool { return u.ID == str(args[0]) }), nil
+	case "RevokeAPIKey":
+		var n int64
+		for i, u := range db.users {
+			if u.ApiKey == str(args[2]) && !u.ApiKeyRevokedAt.Valid {
+				db.users[i].ApiKeyRevokedAt, db.users[i].UpdatedAt = nullStr(args[0]), str(args[1])
+				n++
+			}
+		}
+		return n, nil
+	case "CreateAPIKey":
+		db.apiKeys = append(db.apiKeys, database.ApiKey{
+			ID: str(args[0]), CreatedAt: str(args[1]), UpdatedAt: str(args[2]), UserID: str(args[3]), Name: str(args[4]),
+			KeyHash: str(args[5]), KeyHint: str(args[6]), Scopes: str(args[7]), CreatedVia: str(args[8]), CreatedBy: nullStr(args[9]),
+		})
+		return 1, nil
+	case "DeleteAPIKeysForUser":
+		return deleteWhere(&db.apiKeys, func(k database.ApiKey) bool { return k.UserID == str(args[0]) }), nil
+	case "RenameAPIKey":
+		var n int64
+		for i, k := range db.apiKeys {
+			if k.ID == str(args[2]) && k.UserID == str(args[3]) {
+				db.apiKeys[i].Name, db.apiKeys[i].UpdatedAt = str(args[0]), str(args[1])
+				n++
+			}
+		}
+		return n, nil
+	case "RevokeManagedAPIKey":
+		var n int64
+		for i, k := range db.apiKeys {
+			if k.ID == str(args[2]) && !k.RevokedAt.Valid {
+				db.apiKeys[i].RevokedAt, db.apiKeys[i].UpdatedAt = nullStr(args[0]), str(args[1])
+				n++
+			}
+		}
+		return n, nil
+	case "TouchAPIKey":
+		var n int64
+		for i, k := range db.apiKeys {
+			if k.ID == str(args[1]) {
+				db.apiKeys[i].LastUsedAt = nullStr(args[0])
+				n++
+			}
+		}
+		return n, nil
+	case "CreateNote":
+		db.notes = append(db.notes, database.Note{ID: str(args[0]), CreatedAt: str(args[1]), UpdatedAt: str(args[2]), Note: str(args[3]), UserID: str(args[4])})
+		return 1, nil
+	case "DeleteNotesForUser":
+		return deleteWhere(&db.notes, func(n database.Note) bool { return n.UserID == str(args[0]) }), nil
+	case "DeleteOneTimeTokensForSubject":
+		return deleteWhere(&db.oneTime, func(t database.OneTimeToken) bool { return t.Subject == str(args[0]) }), nil
+	}
+	return 0, fmt.Errorf("fakedb: unsupported exec %s", query)
+}
+
+func (db *fakeDB) query(query string, args []any) ([][]driver.Value, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.failWith != nil {
+		return nil, db.failWith
+	}
+
+	var rows [][]driver.Value
+	switch query {
+	case "GetUser":
+		for _, u := range db.users {
+			if u.ApiKey == str(args[0]) {
+				rows = append(rows, userRow(u))
+			}
+		}
+	case "GetUserByAPIKeyHash":
+		for _, k := range db.apiKeys {
+			if k.KeyHash != str(args[0]) {
+				continue
+			}
+			for _, u := range db.users {
+				if u.ID == k.UserID {
+					rows = append(rows, append(userRow(u), k.ID, nullValue(k.RevokedAt), k.Scopes))
+				}
+			}
+		}
+	case "GetAPIKeyForUser":
+		for _, k := range db.apiKeys {
+			if k.ID == str(args[0]) && k.UserID == str(args[1]) {
+				rows = append(rows, apiKeyRow(k))
+			}
+		}
+	case "ListAPIKeysForUser":
+		keys := append([]database.ApiKey(nil), db.apiKeys...)
+		sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt < keys[j].CreatedAt })
+		for _, k := range keys {
+			if k.UserID == str(args[0]) {
+				rows = append(rows, apiKeyRow(k))
+			}
+		}
+	case "GetNote":
+		for _, n := range db.notes {
+			if n.ID == str(args[0]) {
+				rows = append(rows, noteRow(n))
+			}
+		}
+	case "GetNotesForUser":
+		for _, n := range db.notes {
+			if n.UserID == str(args[0]) {
+				rows = append(rows, noteRow(n))
+			}
+		}
+	default:
+		return nil, fmt.Errorf("fakedb: unsupported query %s", query)
+	}
+	return rows, nil
+}
+
+func deleteWhere[T any](rows *[]T, match func(T) bool) int64 {
+	kept := (*rows)[:0]
+	var n int64
+	for _, r := range *rows {
+		if match(r) {
+			n++
+			continue
+		}
+		kept = append(kept, r)
+	}
+	*rows = kept
+	return n
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+const (
+	eventStreamBuffer    = 64
+	eventStreamHeartbeat = 15 * time.Second
+)
+
+// streamedEvent is the data of each server-sent event.
+type streamedEvent struct {
+	ID       string             `json:"id"`
+	Time     time.Time          `json:"time"`
+	Type     authevents.Type    `json:"type"`
+	Severity string             `json:"severity"`
+	Payload  authevents.Payload `json:"payload"`
+}
+
+// handlerAdminEvents streams auth events live as server-sent events, so
+// operators can watch logins and revocations during an incident.
+// ?severity= sets the minimum severity (default info). ?tenant= is
+// rejected: identities don't carry a tenant yet, so it would match nothing.
+func (cfg *apiConfig) handlerAdminEvents(w http.ResponseWriter, r *http.Request) {
+	minSeverity := authevents.SeverityInfo
+	if v := r.URL.Query().Get("severity"); v != "" {
+		s, err := authevents.ParseSeverity(v)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "severity must be info, warning or critical", err)
+			return
+		}
+		minSeverity = s
+	}
+	if r.URL.Query().Has("tenant") {
+		respondWithError(w, http.StatusBadRequest, "Filtering by tenant isn't supported", nil)
+		return
+	}
+
+	// The server's WriteTimeout would otherwise end the stream.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Streaming unsupported", err)
+		return
+	}
+
+	events, dropped, cancel := cfg.EventStream.Listen(eventStreamBuffer)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+	var reported int64
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			// Tell the client about events it missed by being slow.
+			if n := dropped(); n != reported {
+				reported = n
+				_, err = fmt.Fprintf(w, ": dropped %d events\n\n", n)
+			} else {
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			severity := authevents.SeverityOf(ev.Payload)
+			if severity < minSeverity {
+				continue
+			}
+			err = writeServerSentEvent(w, ev, severity)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			log.Printf("event stream to %s ended: %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, ev authevents.Event, severity authevents.Severity) error {
+	data, err := json.Marshal(streamedEvent{
+		ID:       ev.ID,
+		Time:     ev.Time,
+		Type:     ev.Payload.EventType(),
+		Severity: severity.String(),
+		Payload:  ev.Payload,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Payload.EventType(), data)
+	return err
+}
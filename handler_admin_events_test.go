@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+func TestHandlerAdminEvents_RejectsBadFilters(t *testing.T) {
+	s := newTestServer(t, withAdminAPI, func(cfg *apiConfig) {
+		cfg.EventStream = authevents.NewStream()
+	})
+	for _, query := range []string{"?tenant=acme", "?tenant=", "?severity=loud"} {
+		if w := s.do(t, http.MethodGet, "/admin/events"+query, testAdminKey, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
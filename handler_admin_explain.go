@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+// explainStep is one stage of an auth decision. Outcome is "pass", "fail",
+// "skip" or "note"; a note doesn't affect the decision.
+type explainStep struct {
+	Stage   string `json:"stage"`
+	Outcome string `json:"outcome"`
+	Detail  string `json:"detail"`
+}
+
+// explanation is how middlewareAuth would treat a request, and why.
+type explanation struct {
+	Route   *string       `json:"route"`
+	Allowed bool          `json:"allowed"`
+	Status  int           `json:"status"`
+	Problem string        `json:"problem,omitempty"`
+	Steps   []explainStep `json:"steps"`
+}
+
+func (e *explanation) step(stage, outcome, format string, args ...any) {
+	e.Steps = append(e.Steps, explainStep{Stage: stage, Outcome: outcome, Detail: fmt.Sprintf(format, args...)})
+}
+
+func (e *explanation) deny(status int, problem string) explanation {
+	e.Status, e.Problem = status, problem
+	return *e
+}
+
+// handlerAdminExplain reports how a captured request would be
+// authenticated: which route matched, how the header parsed, what answered
+// the key lookup and which checks allowed or denied it. Nothing is
+// executed on the request's behalf: no handler runs, no events are
+// published and no state the request would change is touched.
+func handlerAdminExplain(cfg *apiConfig, router chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type parameters struct {
+			Method     string              `json:"method"`
+			Path       string              `json:"path"`
+			Headers    map[string][]string `json:"headers"`
+			RemoteAddr string              `json:"remote_addr"`
+		}
+		params := parameters{}
+		if err := decodeJSONBody(r, &params); err != nil {
+			cfg.respondWithDecodeError(w, r, err)
+			return
+		}
+		if params.Method == "" || !strings.HasPrefix(params.Path, "/") {
+			respondWithError(w, http.StatusBadRequest, "method and an absolute path are required", nil)
+			return
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), params.Method, params.Path, nil)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Couldn't build request", err)
+			return
+		}
+		for name, values := range params.Headers {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		req.RemoteAddr = params.RemoteAddr
+
+		respondWithJSON(w, http.StatusOK, cfg.explainAuth(req, router))
+	}
+}
+
+// explainAuth reports the decision middlewareAuth would reach for req,
+// made without acting on it.
+func (cfg *apiConfig) explainAuth(req *http.Request, router chi.Routes) explanation {
+	e := explanation{Steps: []explainStep{}}
+
+	route, ok := routePattern(router, req)
+	if !ok {
+		e.step("route", "fail", "no route for %s %s", req.Method, req.URL.Path)
+		return e.deny(http.StatusNotFound, "")
+	}
+	e.Route = &route
+	e.step("route", "pass", "%s %s, route class %s", req.Method, route, auth.RouteClassOf(req))
+	if reason, ok := authBypass[req.Method+" "+route]; ok {
+		e.step("bypass", "pass", "served without authentication: %s", reason)
+		e.Allowed, e.Status = true, http.StatusOK
+		return e
+	}
+
+	d := cfg.decideAuth(req, route, false)
+	e.Steps = append(e.Steps, d.Steps...)
+	if !d.Allowed {
+		return e.deny(d.Status, d.Problem)
+	}
+	e.Allowed, e.Status = true, http.StatusOK
+	return e
+}
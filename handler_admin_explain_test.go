@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestHandlerAdminExplain(t *testing.T) {
+	s := newTestServer(t, withAdminAPI)
+	user := s.addUser(t, "alice")
+	_, scopeless := s.addKey(t, user.ID)
+	_, revoked := s.addKey(t, user.ID)
+	s.db.apiKeys[len(s.db.apiKeys)-1].RevokedAt.Valid = true
+
+	tests := []struct {
+		name         string
+		method, path string
+		key          string
+		wantAllowed  bool
+		wantStatus   int
+		wantProblem  string
+	}{
+		{"bypassed route", http.MethodGet, "/v1/healthz", "", true, http.StatusOK, ""},
+		{"no route", http.MethodGet, "/v1/nope", "", false, http.StatusNotFound, ""},
+		{"no key", http.MethodGet, "/v1/notes", "", false, http.StatusUnauthorized, problemInvalidAuthHeader},
+		{"unknown key", http.MethodGet, "/v1/notes", "not-a-key", false, http.StatusNotFound, problemUnknownAPIKey},
+		{"revoked key", http.MethodGet, "/v1/notes", revoked, false, http.StatusUnauthorized, problemRevokedAPIKey},
+		{"no scope needed", http.MethodGet, "/v1/keys", scopeless, true, http.StatusOK, ""},
+		{"missing keys:manage", http.MethodPost, "/v1/keys", scopeless, false, http.StatusForbidden, problemInsufficientScope},
+		{"missing account:delete", http.MethodDelete, "/v1/users", scopeless, false, http.StatusForbidden, problemInsufficientScope},
+		{"original key", http.MethodDelete, "/v1/users", user.ApiKey, true, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := map[string]any{"method": tt.method, "path": tt.path}
+			if tt.key != "" {
+				params["headers"] = map[string][]string{"Authorization": {"ApiKey " + tt.key}}
+			}
+			w := s.do(t, http.MethodPost, "/admin/auth/explain", testAdminKey, params)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			var e explanation
+			if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Allowed != tt.wantAllowed || e.Status != tt.wantStatus || e.Problem != tt.wantProblem {
+				t.Errorf("explanation = allowed %v, %d %q, want allowed %v, %d %q; steps %+v",
+					e.Allowed, e.Status, e.Problem, tt.wantAllowed, tt.wantStatus, tt.wantProblem, e.Steps)
+			}
+		})
+	}
+
+	if got := len(s.published(t)); got != 0 {
+		t.Errorf("explaining published %d events, want none", got)
+	}
+}
+
+func TestHandlerAdminExplain_RequiresAdminKey(t *testing.T) {
+	s := newTestServer(t, withAdminAPI)
+	user := s.addUser(t, "alice")
+	body := map[string]any{"method": http.MethodGet, "path": "/v1/notes"}
+
+	if w := s.do(t, http.MethodPost, "/admin/auth/explain", user.ApiKey, body); w.Code != http.StatusForbidden {
+		t.Errorf("user key: status = %d, want 403", w.Code)
+	}
+	if w := s.do(t, http.MethodPost, "/admin/auth/explain", "", body); w.Code != http.StatusUnauthorized {
+		t.Errorf("no key: status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// revokeBatchSize is how many keys are read and revoked per round trip.
+const revokeBatchSize = 100
+
+// errTenantFilter is returned for a tenant filter; keys aren't assigned to
+// tenants in this service.
+var errTenantFilter = errors.New("keys have no tenant to filter on")
+
+// revokeFilter selects keys for bulk revocation. Set fields are ANDed; at
+// least one must be set.
+type revokeFilter struct {
+	Tenant string `json:"tenant"`
+	// Label matches a managed key's name exactly. Users' original keys
+	// have no label and never match.
+	Label         string     `json:"label"`
+	Owner         string     `json:"owner"`
+	CreatedBefore *time.Time `json:"created_before"`
+}
+
+func (f revokeFilter) validate() error {
+	if f.Tenant != "" {
+		return errTenantFilter
+	}
+	if f.Label == "" && f.Owner == "" && f.CreatedBefore == nil {
+		return errors.New("at least one of label, owner or created_before is required")
+	}
+	return nil
+}
+
+func (f revokeFilter) String() string {
+	var parts []string
+	if f.Label != "" {
+		parts = append(parts, fmt.Sprintf("label=%q", f.Label))
+	}
+	if f.Owner != "" {
+		parts = append(parts, "owner="+f.Owner)
+	}
+	if f.CreatedBefore != nil {
+		parts = append(parts, "created_before="+f.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(parts, " ")
+}
+
+// revokeSummary reports what a bulk revocation did, or would do in a dry
+// run. Keys are listed by fingerprint.
+type revokeSummary struct {
+	DryRun  bool     `json:"dry_run"`
+	Matched int      `json:"matched"`
+	Revoked int      `json:"revoked"`
+	Batches int      `json:"batches"`
+	Keys    []string `json:"keys"`
+}
+
+// handlerAdminRevokeKeys revokes every active key matching a filter, e.g.
+// every key created before an incident. With dry_run set nothing is
+// revoked and the summary lists what would be.
+func (cfg *apiConfig) handlerAdminRevokeKeys(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		revokeFilter
+		Reason string `json:"reason"`
+		DryRun bool   `json:"dry_run"`
+	}
+	params := parameters{}
+	if err := decodeJSONBody(r, &params); err != nil {
+		cfg.respondWithDecodeError(w, r, err)
+		return
+	}
+	if err := params.validate(); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "reason is required", nil)
+		return
+	}
+
+	summary, err := cfg.revokeWhere(r, params.revokeFilter, "bulk revocation: "+params.Reason, params.DryRun)
+	if err != nil {
+		// Batches already revoked stay revoked; the summary says how far
+		// it got.
+		respondWithJSON(w, http.StatusInternalServerError, struct {
+			Error string `json:"error"`
+			revokeSummary
+		}{"Couldn't finish revoking keys", summary})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
+// revokeWhere revokes the managed and original user keys matching f, a
+// batch at a time. Keys revoked concurrently count as matched but not
+// revoked. The summary is published as a BulkRevocation event, including
+// when revocation stops part way.
+func (cfg *apiConfig) revokeWhere(r *http.Request, f revokeFilter, reason string, dryRun bool) (revokeSummary, error) {
+	summary := revokeSummary{DryRun: dryRun, Keys: []string{}}
+	defer func() {
+		cfg.Events.Publish(authevents.BulkRevocation{
+			Filter:  f.String(),
+			Reason:  reason,
+			DryRun:  dryRun,
+			Matched: summary.Matched,
+			Revoked: summary.Revoked,
+		})
+	}()
+
+	var createdBefore string
+	if f.CreatedBefore != nil {
+		createdBefore = f.CreatedBefore.UTC().Format(time.RFC3339)
+	}
+	record := func(fingerprint string, revoke func() (bool, error)) error {
+		summary.Matched++
+		summary.Keys = append(summary.Keys, fingerprint)
+		if dryRun {
+			return nil
+		}
+		revoked, err := revoke()
+		if revoked {
+			summary.Revoked++
+		}
+		return err
+	}
+
+	for after := ""; ; {
+		keys, err := cfg.DB.ListActiveAPIKeysPage(r.Context(), database.ListActiveAPIKeysPageParams{
+			After:         after,
+			UserID:        f.Owner,
+			CreatedBefore: createdBefore,
+			Name:          f.Label,
+			PageSize:      revokeBatchSize,
+		})
+		if err != nil {
+			return summary, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+		summary.Batches++
+		for _, key := range keys {
+			err := record(auth.FingerprintFromHash(key.KeyHash), func() (bool, error) {
+				return cfg.revokeManagedKey(r, key, reason)
+			})
+			if err != nil {
+				return summary, err
+			}
+		}
+		after = keys[len(keys)-1].ID
+	}
+
+	if f.Label != "" {
+		return summary, nil
+	}
+	for after := ""; ; {
+		users, err := cfg.DB.ListActiveUserKeysPage(r.Context(), database.ListActiveUserKeysPageParams{
+			After:         after,
+			ID:            f.Owner,
+			CreatedBefore: createdBefore,
+			PageSize:      revokeBatchSize,
+		})
+		if err != nil {
+			return summary, err
+		}
+		if len(users) == 0 {
+			break
+		}
+		summary.Batches++
+		for _, user := range users {
+			err := record(auth.Fingerprint(user.ApiKey), func() (bool, error) {
+				return cfg.revokeUserAPIKey(r, user.ApiKey, reason)
+			})
+			if err != nil {
+				return summary, err
+			}
+		}
+		after = users[len(users)-1].ID
+	}
+	return summary, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authstats"
+)
+
+const (
+	defaultStatsTopKeys = 10
+	maxStatsTopKeys     = 100
+)
+
+// handlerAdminStats reports aggregate auth figures for dashboards. Event
+// figures cover this instance since it started; active_keys is read from
+// the database and is null without one.
+func (cfg *apiConfig) handlerAdminStats(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		ActiveKeys *int64 `json:"active_keys"`
+		authstats.Snapshot
+	}
+
+	top := defaultStatsTopKeys
+	if v := r.URL.Query().Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsTopKeys {
+			respondWithError(w, http.StatusBadRequest, "top must be between 1 and 100", err)
+			return
+		}
+		top = n
+	}
+
+	resp := response{Snapshot: cfg.Stats.Snapshot(top)}
+	if cfg.DB != nil {
+		active, err := cfg.DB.CountActiveAPIKeys(r.Context())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't count active keys", err)
+			return
+		}
+		resp.ActiveKeys = &active
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
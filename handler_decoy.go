@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// handlerDecoy answers a request authenticated by a honeytoken in place of
+// the route's real handler. Responses are shaped like the real ones so the
+// caller can't tell the key is a decoy, but nothing is read from or
+// written to the database: the decoy user has no notes or keys, writes
+// are echoed back without being stored, and it isn't allowed to manage
+// keys or delete itself, as a typical CI key wouldn't be.
+func (cfg *apiConfig) handlerDecoy(w http.ResponseWriter, r *http.Request, user database.User) {
+	switch path := r.URL.Path; {
+	case r.Method == http.MethodGet && path == "/v1/users":
+		cfg.handlerUsersGet(w, r, user)
+	case r.Method == http.MethodGet && (path == "/v1/notes" || path == "/v1/keys"):
+		respondWithJSON(w, http.StatusOK, []struct{}{})
+	case r.Method == http.MethodPost && path == "/v1/notes":
+		params := struct {
+			Note string `json:"note"`
+		}{}
+		if err := decodeJSONBody(r, &params); err != nil {
+			cfg.respondWithDecodeError(w, r, err)
+			return
+		}
+		now := cfg.Clock.Now().UTC().Truncate(time.Second)
+		respondWithJSON(w, http.StatusCreated, Note{
+			ID:        uuid.New().String(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Note:      params.Note,
+			UserID:    user.ID,
+		})
+	case path == "/v1/keys" || strings.HasPrefix(path, "/v1/keys/"):
+		cfg.respondWithAuthError(w, r, http.StatusForbidden, problemInsufficientScope, "API key isn't allowed to manage keys", nil)
+	case r.Method == http.MethodDelete && path == "/v1/users":
+		cfg.respondWithAuthError(w, r, http.StatusForbidden, problemInsufficientScope, "API key isn't allowed to delete the user", nil)
+	default:
+		respondWithError(w, http.StatusNotFound, "Not found", nil)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+func TestHandlerDecoy(t *testing.T) {
+	decoy, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *apiConfig) {
+		cfg.Honeytokens = auth.ParseHoneytokens(auth.Fingerprint(decoy))
+	})
+
+	tests := []struct {
+		method, path string
+		body         any
+		want         int
+	}{
+		{http.MethodGet, "/v1/users", nil, http.StatusOK},
+		{http.MethodGet, "/v1/notes", nil, http.StatusOK},
+		{http.MethodPost, "/v1/notes", map[string]string{"note": "exfil"}, http.StatusCreated},
+		{http.MethodGet, "/v1/keys", nil, http.StatusOK},
+		{http.MethodPost, "/v1/keys", map[string]string{"name": "backdoor"}, http.StatusForbidden},
+		{http.MethodDelete, "/v1/keys/some-key", nil, http.StatusForbidden},
+		{http.MethodDelete, "/v1/users", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := s.do(t, tt.method, tt.path, decoy, tt.body)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d; body %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+		}
+	}
+
+	first, second := decode(t, s.do(t, http.MethodGet, "/v1/users", decoy, nil)), decode(t, s.do(t, http.MethodGet, "/v1/users", decoy, nil))
+	apiKey, _ := first["api_key"].(string)
+	if err := auth.VerifyKeyChecksum(apiKey); err != nil || !strings.HasPrefix(apiKey, auth.KeyPrefix) || apiKey == decoy {
+		t.Errorf("decoy user api_key = %q, want a well-formed key other than the decoy", apiKey)
+	}
+	if id, err := uuid.Parse(first["id"].(string)); err != nil || id.Version() != 4 {
+		t.Errorf("decoy user id = %v, want a version 4 UUID", first["id"])
+	}
+	for _, field := range []string{"id", "name", "api_key"} {
+		if first[field] == "" || first[field] != second[field] {
+			t.Errorf("decoy user %s = %v then %v, want the same non-empty value", field, first[field], second[field])
+		}
+	}
+
+	if len(s.db.users) != 0 || len(s.db.notes) != 0 || len(s.db.apiKeys) != 0 {
+		t.Errorf("decoy requests wrote to the database")
+	}
+	used := 0
+	for _, p := range s.published(t) {
+		switch p := p.(type) {
+		case authevents.HoneytokenUsed:
+			used++
+			if p.KeyFingerprint != auth.Fingerprint(decoy) {
+				t.Errorf("HoneytokenUsed fingerprint = %q, want the decoy's", p.KeyFingerprint)
+			}
+		case authevents.Login:
+			t.Errorf("decoy request published a Login: %+v", p)
+		}
+	}
+	if used != len(tests)+2 {
+		t.Errorf("HoneytokenUsed published %d times, want %d", used, len(tests)+2)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// erasureReport tells the user what was deleted. SubjectHash is how the
+// erasure is referred to in the audit trail from now on.
+type erasureReport struct {
+	SubjectHash    string    `json:"subject_hash"`
+	ErasedAt       time.Time `json:"erased_at"`
+	NotesDeleted   int64     `json:"notes_deleted"`
+	APIKeysDeleted int64     `json:"api_keys_deleted"`
+	Retained       []string  `json:"retained"`
+}
+
+// handlerUsersDelete erases the authenticated user: their notes, their
+// managed keys, their outstanding one-time tokens and the user record
+// itself, in one transaction. It needs auth.ScopeAccountDelete. Audit
+// events already emitted only carry key fingerprints, and the erasure is
+// recorded under a hash of the user ID.
+func (cfg *apiConfig) handlerUsersDelete(w http.ResponseWriter, r *http.Request, user database.User) {
+	identity, _ := auth.FromContext(r.Context())
+	if err := auth.AuthorizeAccountDeletion(identity, user.ID); err != nil {
+		cfg.respondWithAuthError(w, r, http.StatusForbidden, problemInsufficientScope, "API key isn't allowed to delete the user", err)
+		return
+	}
+
+	keys, err := cfg.DB.ListAPIKeysForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get keys for user", err)
+		return
+	}
+
+	tx, err := cfg.DBConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := cfg.DB.WithTx(tx)
+
+	notes, err := q.DeleteNotesForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+	apiKeys, err := q.DeleteAPIKeysForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+	if _, err := q.DeleteOneTimeTokensForSubject(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+	if _, err := q.DeleteUser(r.Context(), user.ID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't erase user", err)
+		return
+	}
+
+	cfg.Users.Invalidate(auth.HashKey(user.ApiKey))
+	for _, key := range keys {
+		cfg.Users.Invalidate(key.KeyHash)
+	}
+
+	sum := sha256.Sum256([]byte(user.ID))
+	report := erasureReport{
+		SubjectHash:    hex.EncodeToString(sum[:]),
+		ErasedAt:       cfg.Clock.Now().UTC(),
+		NotesDeleted:   notes,
+		APIKeysDeleted: apiKeys,
+		Retained: []string{
+			"auth event logs, which identify keys by fingerprint only",
+			"this erasure's record, which identifies you by subject_hash only",
+		},
+	}
+	cfg.Events.Publish(authevents.IdentityErased{
+		SubjectHash:    report.SubjectHash,
+		NotesDeleted:   notes,
+		APIKeysDeleted: apiKeys,
+	})
+	respondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+func TestHandlerUsersDelete_RequiresAccountDeleteScope(t *testing.T) {
+	s := newTestServer(t)
+	user := s.addUser(t, "alice")
+	_, manager := s.addKey(t, user.ID, auth.ScopeKeysManage)
+	_, scopeless := s.addKey(t, user.ID)
+
+	for name, key := range map[string]string{"keys:manage": manager, "scopeless": scopeless} {
+		w := s.do(t, http.MethodDelete, "/v1/users", key, nil)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s key: status = %d, want 403; body %s", name, w.Code, w.Body)
+		}
+	}
+	if len(s.db.users) != 1 {
+		t.Fatalf("user was erased by a key without %s", auth.ScopeAccountDelete)
+	}
+}
+
+func TestHandlerUsersDelete_ErasesEverything(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		scoped bool
+	}{
+		{"original key", false},
+		{"account:delete key", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			user := s.addUser(t, "alice")
+			other := s.addUser(t, "bob")
+			key := user.ApiKey
+			if tt.scoped {
+				_, key = s.addKey(t, user.ID, auth.ScopeAccountDelete)
+			}
+			s.db.notes = append(s.db.notes, database.Note{ID: "n1", UserID: user.ID}, database.Note{ID: "n2", UserID: other.ID})
+			s.db.oneTime = append(s.db.oneTime,
+				database.OneTimeToken{TokenHash: "t1", Purpose: "password_reset", Subject: user.ID},
+				database.OneTimeToken{TokenHash: "t2", Purpose: "password_reset", Subject: other.ID},
+			)
+
+			w := s.do(t, http.MethodDelete, "/v1/users", key, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			if len(s.db.users) != 1 || s.db.users[0].ID != other.ID {
+				t.Errorf("users = %+v, want only bob", s.db.users)
+			}
+			if len(s.db.notes) != 1 || s.db.notes[0].UserID != other.ID {
+				t.Errorf("notes = %+v, want only bob's", s.db.notes)
+			}
+			if len(s.db.apiKeys) != 0 {
+				t.Errorf("api keys = %+v, want none", s.db.apiKeys)
+			}
+			if len(s.db.oneTime) != 1 || s.db.oneTime[0].Subject != other.ID {
+				t.Errorf("one-time tokens = %+v, want only bob's", s.db.oneTime)
+			}
+
+			if w := s.do(t, http.MethodGet, "/v1/users", key, nil); w.Code != http.StatusNotFound {
+				t.Errorf("after erasure: status = %d, want 404", w.Code)
+			}
+		})
+	}
+}
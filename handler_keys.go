@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+)
+
+// maxKeyNameLength bounds the label a user can give a managed key.
+const maxKeyNameLength = 100
+
+// The flows that issue keys, recorded as their provenance.
+const (
+	keyCreatedViaSignup = "signup"
+	keyCreatedViaAPI    = "api"
+)
+
+// The handlers below let users manage their own keys. Every query is scoped
+// to the authenticated user, so another user's key ID reads as not found.
+// Changing keys also needs the auth.ScopeKeysManage scope.
+
+func (cfg *apiConfig) handlerKeysList(w http.ResponseWriter, r *http.Request, user database.User) {
+	keys, err := cfg.DB.ListAPIKeysForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get keys for user", err)
+		return
+	}
+
+	keysResp, err := databaseAPIKeysToAPIKeys(keys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't convert keys", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, keysResp)
+}
+
+func (cfg *apiConfig) handlerKeysCreate(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+		// Scopes defaults to the caller's own.
+		Scopes *[]string `json:"scopes"`
+	}
+	if !cfg.authorizeKeyManagement(w, r, user.ID) {
+		return
+	}
+	params := parameters{}
+	err := decodeJSONBody(r, &params)
+	if err != nil {
+		cfg.respondWithDecodeError(w, r, err)
+		return
+	}
+	if !validKeyName(params.Name) {
+		respondWithError(w, http.StatusBadRequest, "Key name must be 1 to 100 characters", nil)
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	scopes := identity.Scopes
+	if params.Scopes != nil {
+		scopes = *params.Scopes
+	}
+	switch err := auth.AuthorizeGrant(identity, scopes); {
+	case errors.Is(err, auth.ErrUnknownScope):
+		respondWithError(w, http.StatusBadRequest, "Unknown scope", err)
+		return
+	case err != nil:
+		cfg.respondWithAuthError(w, r, http.StatusForbidden, problemInsufficientScope, "Can't grant a scope the api key doesn't have", err)
+		return
+	}
+
+	apiKey, err := auth.GenerateAPIKey()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't gen apikey", err)
+		return
+	}
+
+	id := uuid.New().String()
+	now := cfg.timestamp()
+	err = cfg.DB.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		ID:         id,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		UserID:     user.ID,
+		Name:       params.Name,
+		KeyHash:    auth.HashKey(apiKey),
+		KeyHint:    auth.Mask(apiKey),
+		Scopes:     auth.FormatScopes(scopes),
+		CreatedVia: keyCreatedViaAPI,
+		CreatedBy:  sql.NullString{String: identity.CredentialID, Valid: identity.CredentialID != ""},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create key", err)
+		return
+	}
+
+	cfg.Events.Publish(authevents.KeyCreated{
+		UserID:         user.ID,
+		KeyFingerprint: auth.Fingerprint(apiKey),
+		Via:            keyCreatedViaAPI,
+		CreatedBy:      identity.CredentialID,
+	})
+
+	key, ok := cfg.getOwnAPIKey(w, r, id, user)
+	if !ok {
+		return
+	}
+	keyResp, err := databaseAPIKeyToAPIKey(key)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't convert key", err)
+		return
+	}
+	keyResp.Key = apiKey
+	respondWithJSON(w, http.StatusCreated, keyResp)
+}
+
+func (cfg *apiConfig) handlerKeysRename(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+	if !cfg.authorizeKeyManagement(w, r, user.ID) {
+		return
+	}
+	params := parameters{}
+	err := decodeJSONBody(r, &params)
+	if err != nil {
+		cfg.respondWithDecodeError(w, r, err)
+		return
+	}
+	if !validKeyName(params.Name) {
+		respondWithError(w, http.StatusBadRequest, "Key name must be 1 to 100 characters", nil)
+		return
+	}
+
+	id := chi.URLParam(r, "keyID")
+	if _, ok := cfg.getOwnAPIKey(w, r, id, user); !ok {
+		return
+	}
+	err = cfg.DB.RenameAPIKey(r.Context(), database.RenameAPIKeyParams{
+		Name:      params.Name,
+		UpdatedAt: cfg.timestamp(),
+		ID:        id,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't rename key", err)
+		return
+	}
+
+	key, ok := cfg.getOwnAPIKey(w, r, id, user)
+	if !ok {
+		return
+	}
+	keyResp, err := databaseAPIKeyToAPIKey(key)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't convert key", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, keyResp)
+}
+
+// handlerKeysRevoke revokes one of the caller's keys. Revoking a key that is
+// already revoked succeeds without changing its revocation time.
+func (cfg *apiConfig) handlerKeysRevoke(w http.ResponseWriter, r *http.Request, user database.User) {
+	if !cfg.authorizeKeyManagement(w, r, user.ID) {
+		return
+	}
+	key, ok := cfg.getOwnAPIKey(w, r, chi.URLParam(r, "keyID"), user)
+	if !ok {
+		return
+	}
+	if _, err := cfg.revokeManagedKey(r, key, "revoked by owner"); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke key", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getOwnAPIKey loads the key with the given ID if user owns it. On failure it
+// writes the error response and returns ok == false.
+func (cfg *apiConfig) getOwnAPIKey(w http.ResponseWriter, r *http.Request, id string, user database.User) (key database.ApiKey, ok bool) {
+	key, err := cfg.DB.GetAPIKeyForUser(r.Context(), database.GetAPIKeyForUserParams{
+		ID:     id,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Couldn't get key", nil)
+		return key, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get key", err)
+		return key, false
+	}
+	return key, true
+}
+
+// authorizeKeyManagement writes a 403 and returns false unless the caller
+// may manage keys owned by ownerID.
+func (cfg *apiConfig) authorizeKeyManagement(w http.ResponseWriter, r *http.Request, ownerID string) bool {
+	identity, _ := auth.FromContext(r.Context())
+	if err := auth.AuthorizeKeyManagement(identity, ownerID); err != nil {
+		cfg.respondWithAuthError(w, r, http.StatusForbidden, problemInsufficientScope, "API key isn't allowed to manage keys", err)
+		return false
+	}
+	return true
+}
+
+// revokeManagedKey revokes key, evicts it from the resolver cache and
+// publishes the revocation. It reports false if key was already revoked.
+func (cfg *apiConfig) revokeManagedKey(r *http.Request, key database.ApiKey, reason string) (bool, error) {
+	ctx, cancel := withTimeout(r.Context(), cfg.Timeouts.KeyWrite)
+	defer cancel()
+
+	now := cfg.timestamp()
+	revoked, err := cfg.DB.RevokeManagedAPIKey(ctx, database.RevokeManagedAPIKeyParams{
+		RevokedAt: sql.NullString{String: now, Valid: true},
+		UpdatedAt: now,
+		ID:        key.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if revoked > 0 {
+		cfg.Users.Invalidate(key.KeyHash)
+		cfg.Events.Publish(authevents.KeyRevoked{KeyFingerprint: auth.FingerprintFromHash(key.KeyHash), Reason: reason})
+	}
+	return revoked > 0, nil
+}
+
+// revokeUserAPIKey is revokeManagedKey for a user's original, unmanaged
+// key.
+func (cfg *apiConfig) revokeUserAPIKey(r *http.Request, apiKey, reason string) (bool, error) {
+	ctx, cancel := withTimeout(r.Context(), cfg.Timeouts.KeyWrite)
+	defer cancel()
+
+	now := cfg.timestamp()
+	revoked, err := cfg.DB.RevokeAPIKey(ctx, database.RevokeAPIKeyParams{
+		ApiKeyRevokedAt: sql.NullString{String: now, Valid: true},
+		UpdatedAt:       now,
+		ApiKey:          apiKey,
+	})
+	if err != nil {
+		return false, err
+	}
+	if revoked > 0 {
+		cfg.Users.Invalidate(auth.HashKey(apiKey))
+		cfg.Events.Publish(authevents.KeyRevoked{KeyFingerprint: auth.Fingerprint(apiKey), Reason: reason})
+	}
+	return revoked > 0, nil
+}
+
+// keyTouch records that a managed key was used at a time.
+type keyTouch struct {
+	KeyID string
+	At    string
+}
+
+// touchAPIKey records that a managed key was just used. The write is
+// queued on KeyTouches rather than made inline, so the database round trip
+// isn't part of auth latency; under load, touches are dropped rather than
+// holding up requests.
+func (cfg *apiConfig) touchAPIKey(r *http.Request, keyID string) {
+	cfg.KeyTouches.Add(r.Context(), keyTouch{KeyID: keyID, At: cfg.timestamp()})
+}
+
+// writeKeyTouches is the KeyTouches flush. Only the latest use of each key
+// matters, so a batch becomes one update per distinct key, in a single
+// transaction.
+func (cfg *apiConfig) writeKeyTouches(ctx context.Context, touches []keyTouch) error {
+	ctx, cancel := withTimeout(ctx, cfg.Timeouts.KeyWrite)
+	defer cancel()
+
+	latest := map[string]string{}
+	for _, t := range touches {
+		if t.At > latest[t.KeyID] {
+			latest[t.KeyID] = t.At
+		}
+	}
+
+	tx, err := cfg.DBConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	q := cfg.DB.WithTx(tx)
+	for keyID, at := range latest {
+		err := q.TouchAPIKey(ctx, database.TouchAPIKeyParams{
+			LastUsedAt: sql.NullString{String: at, Valid: true},
+			ID:         keyID,
+		})
+		if err != nil {
+			return fmt.Errorf("touch key %s: %w", keyID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func validKeyName(name string) bool {
+	return name != "" && len(name) <= maxKeyNameLength
+}
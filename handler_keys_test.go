@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+func TestManagedKeyResponsesNeverContainOriginalKey(t *testing.T) {
+	s := newTestServer(t)
+	user := s.addUser(t, "alice")
+	keyID, managed := s.addKey(t, user.ID, auth.KnownScopes...)
+
+	requests := []struct {
+		method, path string
+		body         any
+	}{
+		{http.MethodGet, "/v1/users", nil},
+		{http.MethodPost, "/v1/notes", map[string]string{"note": "hi"}},
+		{http.MethodGet, "/v1/notes", nil},
+		{http.MethodGet, "/v1/keys", nil},
+		{http.MethodPost, "/v1/keys", map[string]string{"name": "ci"}},
+		{http.MethodPut, "/v1/keys/" + keyID, map[string]string{"name": "renamed"}},
+		{http.MethodPost, "/v1/keys/nope", nil},
+		{http.MethodDelete, "/v1/keys/" + keyID, nil},
+	}
+	for _, req := range requests {
+		w := s.do(t, req.method, req.path, managed, req.body)
+		if w.Code >= 500 {
+			t.Errorf("%s %s: status = %d, body %s", req.method, req.path, w.Code, w.Body)
+		}
+		if body := w.Body.String(); strings.Contains(body, user.ApiKey) {
+			t.Errorf("%s %s: response contains the original key: %s", req.method, req.path, body)
+		}
+	}
+}
+
+func TestHandlerKeys_ScopeChecks(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.addUser(t, "alice")
+	bob := s.addUser(t, "bob")
+	_, manager := s.addKey(t, alice.ID, auth.ScopeKeysManage)
+	_, scopeless := s.addKey(t, alice.ID)
+	_, deleter := s.addKey(t, alice.ID, auth.ScopeAccountDelete)
+	aliceKey, _ := s.addKey(t, alice.ID)
+	bobKey, _ := s.addKey(t, bob.ID)
+
+	tests := []struct {
+		name         string
+		key          string
+		method, path string
+		body         any
+		want         int
+	}{
+		{"list without scopes", scopeless, http.MethodGet, "/v1/keys", nil, http.StatusOK},
+		{"create without scopes", scopeless, http.MethodPost, "/v1/keys", map[string]any{"name": "ci"}, http.StatusForbidden},
+		{"create with another scope", deleter, http.MethodPost, "/v1/keys", map[string]any{"name": "ci"}, http.StatusForbidden},
+		{"rename without scopes", scopeless, http.MethodPut, "/v1/keys/" + aliceKey, map[string]any{"name": "ci"}, http.StatusForbidden},
+		{"revoke without scopes", scopeless, http.MethodDelete, "/v1/keys/" + aliceKey, nil, http.StatusForbidden},
+		{"create", manager, http.MethodPost, "/v1/keys", map[string]any{"name": "ci"}, http.StatusCreated},
+		{"create granting an unheld scope", manager, http.MethodPost, "/v1/keys", map[string]any{"name": "ci", "scopes": []string{auth.ScopeAccountDelete}}, http.StatusForbidden},
+		{"create granting an unknown scope", manager, http.MethodPost, "/v1/keys", map[string]any{"name": "ci", "scopes": []string{"keys:everything"}}, http.StatusBadRequest},
+		{"create granting no scopes", manager, http.MethodPost, "/v1/keys", map[string]any{"name": "ci", "scopes": []string{}}, http.StatusCreated},
+		{"original key grants any scope", alice.ApiKey, http.MethodPost, "/v1/keys", map[string]any{"name": "ci", "scopes": auth.KnownScopes}, http.StatusCreated},
+		{"rename", manager, http.MethodPut, "/v1/keys/" + aliceKey, map[string]any{"name": "renamed"}, http.StatusOK},
+		{"rename another user's key", manager, http.MethodPut, "/v1/keys/" + bobKey, map[string]any{"name": "mine"}, http.StatusNotFound},
+		{"revoke another user's key", manager, http.MethodDelete, "/v1/keys/" + bobKey, nil, http.StatusNotFound},
+		{"revoke", manager, http.MethodDelete, "/v1/keys/" + aliceKey, nil, http.StatusNoContent},
+		{"revoke again", manager, http.MethodDelete, "/v1/keys/" + aliceKey, nil, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(t, tt.method, tt.path, tt.key, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	for _, k := range s.db.apiKeys {
+		if k.ID == bobKey && (k.Name != "test" || k.RevokedAt.Valid) {
+			t.Errorf("bob's key was changed: %+v", k)
+		}
+	}
+}
+
+func TestHandlerKeys_RevokedKeyIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	user := s.addUser(t, "alice")
+	_, manager := s.addKey(t, user.ID, auth.ScopeKeysManage)
+	id, victim := s.addKey(t, user.ID)
+
+	if w := s.do(t, http.MethodGet, "/v1/notes", victim, nil); w.Code != http.StatusOK {
+		t.Fatalf("before revocation: status = %d", w.Code)
+	}
+	if w := s.do(t, http.MethodDelete, "/v1/keys/"+id, manager, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("revoke: status = %d, body %s", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodGet, "/v1/notes", victim, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("after revocation: status = %d, want 401", w.Code)
+	}
+}
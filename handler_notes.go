@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"net/http"
-	"time"
 
 	"github.com/bootdotdev/learn-cicd-starter/internal/database"
 	"github.com/google/uuid"
@@ -29,19 +27,18 @@ func (cfg *apiConfig) handlerNotesCreate(w http.ResponseWriter, r *http.Request,
 	type parameters struct {
 		Note string `json:"note"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSONBody(r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		cfg.respondWithDecodeError(w, r, err)
 		return
 	}
 
 	id := uuid.New().String()
 	err = cfg.DB.CreateNote(r.Context(), database.CreateNoteParams{
 		ID:        id,
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedAt: cfg.timestamp(),
+		UpdatedAt: cfg.timestamp(),
 		Note:      params.Note,
 		UserID:    user.ID,
 	})
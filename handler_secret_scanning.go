@@ -0,0 +1,90 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secretscan"
+)
+
+const maxSecretScanningBody = 1 << 20
+
+func (cfg *apiConfig) handlerSecretScanning(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSecretScanningBody))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read report", err)
+		return
+	}
+
+	err = cfg.SecretScanning.Verify(
+		r.Context(),
+		r.Header.Get(secretscan.KeyIdentifierHeader),
+		r.Header.Get(secretscan.SignatureHeader),
+		body,
+	)
+	if err != nil {
+		cfg.respondWithAuthError(w, r, http.StatusUnauthorized, problemInvalidSignature, "Couldn't verify report signature", err)
+		return
+	}
+
+	matches := []secretscan.Match{}
+	if err := json.Unmarshal(body, &matches); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode report", err)
+		return
+	}
+
+	results := make([]secretscan.Result, 0, len(matches))
+	for _, match := range matches {
+		label, err := cfg.revokeLeakedKey(r, match)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't process report", err)
+			return
+		}
+		results = append(results, secretscan.Result{
+			TokenHash: secretscan.HashToken(match.Token),
+			TokenType: match.Type,
+			Label:     label,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, results)
+}
+
+func (cfg *apiConfig) revokeLeakedKey(r *http.Request, match secretscan.Match) (secretscan.Label, error) {
+	if auth.VerifyKeyChecksum(match.Token) != nil {
+		return secretscan.FalsePositive, nil
+	}
+	reason := "reported by secret scanning at " + match.URL
+	_, err := cfg.DB.GetUser(r.Context(), match.Token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.revokeLeakedManagedKey(r, match.Token, reason)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := cfg.revokeUserAPIKey(r, match.Token, reason); err != nil {
+		return "", err
+	}
+	return secretscan.TruePositive, nil
+}
+
+func (cfg *apiConfig) revokeLeakedManagedKey(r *http.Request, token, reason string) (secretscan.Label, error) {
+	hash := auth.HashKey(token)
+	row, err := cfg.DB.GetUserByAPIKeyHash(r.Context(), hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return secretscan.FalsePositive, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := cfg.revokeManagedKey(r, database.ApiKey{ID: row.KeyID, KeyHash: hash}, reason); err != nil {
+		return "", err
+	}
+	return secretscan.TruePositive, nil
+}
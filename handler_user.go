@@ -1,13 +1,10 @@
 package main
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"net/http"
-	"time"
 
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
 	"github.com/bootdotdev/learn-cicd-starter/internal/database"
 	"github.com/google/uuid"
 )
@@ -16,15 +13,14 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 	type parameters struct {
 		Name string `json:"name"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	err := decodeJSONBody(r, &params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		cfg.respondWithDecodeError(w, r, err)
 		return
 	}
 
-	apiKey, err := generateRandomSHA256Hash()
+	apiKey, err := auth.GenerateAPIKey()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't gen apikey", err)
 		return
@@ -32,8 +28,8 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 
 	err = cfg.DB.CreateUser(r.Context(), database.CreateUserParams{
 		ID:        uuid.New().String(),
-		CreatedAt: time.Now().UTC().Format(time.RFC3339),
-		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		CreatedAt: cfg.timestamp(),
+		UpdatedAt: cfg.timestamp(),
 		Name:      params.Name,
 		ApiKey:    apiKey,
 	})
@@ -48,6 +44,8 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	cfg.Events.Publish(authevents.KeyCreated{UserID: user.ID, KeyFingerprint: auth.Fingerprint(apiKey), Via: keyCreatedViaSignup})
+
 	userResp, err := databaseUserToUser(user)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't convert user", err)
@@ -56,24 +54,17 @@ func (cfg *apiConfig) handlerUsersCreate(w http.ResponseWriter, r *http.Request)
 	respondWithJSON(w, http.StatusCreated, userResp)
 }
 
-func generateRandomSHA256Hash() (string, error) {
-	randomBytes := make([]byte, 32)
-	_, err := rand.Read(randomBytes)
-	if err != nil {
-		return "", err
-	}
-	hash := sha256.Sum256(randomBytes)
-	hashString := hex.EncodeToString(hash[:])
-	return hashString, nil
-}
-
 func (cfg *apiConfig) handlerUsersGet(w http.ResponseWriter, r *http.Request, user database.User) {
-
 	userResp, err := databaseUserToUser(user)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't convert user", err)
 		return
 	}
+	// The original key grants every scope, so only show it to a caller who
+	// already holds it.
+	if identity, _ := auth.FromContext(r.Context()); identity.Attr(auth.AttrPrimaryKey) == "" {
+		userResp.ApiKey = ""
+	}
 
 	respondWithJSON(w, http.StatusOK, userResp)
 }
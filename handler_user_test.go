@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+func TestHandlerUsersGet_APIKeyOnlyShownToOriginalKey(t *testing.T) {
+	s := newTestServer(t)
+	user := s.addUser(t, "alice")
+	_, scoped := s.addKey(t, user.ID, auth.ScopeKeysManage)
+	_, scopeless := s.addKey(t, user.ID)
+
+	w := s.do(t, http.MethodGet, "/v1/users", user.ApiKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("original key: status = %d, body %s", w.Code, w.Body)
+	}
+	if got := decode(t, w)["api_key"]; got != user.ApiKey {
+		t.Errorf("original key: api_key = %v, want the caller's key", got)
+	}
+
+	for name, key := range map[string]string{"scoped": scoped, "scopeless": scopeless} {
+		w := s.do(t, http.MethodGet, "/v1/users", key, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s key: status = %d, body %s", name, w.Code, w.Body)
+		}
+		body := decode(t, w)
+		if _, ok := body["api_key"]; ok {
+			t.Errorf("%s key: response includes api_key: %s", name, w.Body)
+		}
+		if body["id"] != user.ID {
+			t.Errorf("%s key: id = %v, want %s", name, body["id"], user.ID)
+		}
+	}
+}
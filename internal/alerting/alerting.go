@@ -0,0 +1,163 @@
+// Package alerting turns streams of security-relevant occurrences into
+// deduplicated alerts and delivers them to external sinks.
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// Severity follows PagerDuty's levels, which the other sinks reuse.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityError    Severity = "error"
+	SeverityCritical Severity = "critical"
+)
+
+// Rule fires once Threshold occurrences for the same subject fall within
+// Window.
+type Rule struct {
+	Name      string
+	Severity  Severity
+	Threshold int
+	Window    time.Duration
+}
+
+// Alert is a fired rule. DedupKey is the same for every alert about the
+// same rule and subject, so sinks can group them.
+type Alert struct {
+	Rule     string
+	Severity Severity
+	Subject  string
+	Summary  string
+	DedupKey string
+	Time     time.Time
+}
+
+// Sink delivers alerts somewhere people will see them.
+type Sink interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// Alerter counts occurrences per rule and subject and fires alerts, at
+// most once per subject per dedup window. It is safe for concurrent use.
+type Alerter struct {
+	rules map[string]Rule
+	dedup time.Duration
+	sinks []Sink
+	clock clock.Clock
+
+	mu     sync.Mutex
+	counts map[string]*window
+	fired  map[string]time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// New returns an Alerter evaluating rules and sending to sinks.
+func New(rules []Rule, dedup time.Duration, sinks ...Sink) *Alerter {
+	byName := make(map[string]Rule, len(rules))
+	for _, r := range rules {
+		byName[r.Name] = r
+	}
+	return &Alerter{
+		rules:  byName,
+		dedup:  dedup,
+		sinks:  sinks,
+		clock:  clock.Real,
+		counts: map[string]*window{},
+		fired:  map[string]time.Time{},
+	}
+}
+
+// WithClock makes a read time from c. It must be called before a is used.
+func (a *Alerter) WithClock(c clock.Clock) *Alerter {
+	a.clock = c
+	return a
+}
+
+// Record notes one occurrence for rule about subject, e.g. a key
+// fingerprint, or "" for service-wide rules. It returns the alert to send
+// if the rule fires and hasn't fired for subject within the dedup window.
+// Occurrences for unknown rules are ignored.
+func (a *Alerter) Record(rule, subject, summary string) (Alert, bool) {
+	r, ok := a.rules[rule]
+	if !ok {
+		return Alert{}, false
+	}
+	key := rule + ":" + subject
+	now := a.clock.Now()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sweep(now)
+
+	w, ok := a.counts[key]
+	if !ok || now.Sub(w.start) >= r.Window {
+		w = &window{start: now}
+		a.counts[key] = w
+	}
+	w.count++
+	if w.count < r.Threshold {
+		return Alert{}, false
+	}
+	if last, ok := a.fired[key]; ok && now.Sub(last) < a.dedup {
+		return Alert{}, false
+	}
+	a.fired[key] = now
+	delete(a.counts, key)
+
+	return Alert{
+		Rule:     rule,
+		Severity: r.Severity,
+		Subject:  subject,
+		Summary:  summary,
+		DedupKey: key,
+		Time:     now,
+	}, true
+}
+
+// sweep forgets dedup entries and windows old enough not to matter. The
+// longest rule window bounds how long a count can stay relevant.
+func (a *Alerter) sweep(now time.Time) {
+	for key, t := range a.fired {
+		if now.Sub(t) >= a.dedup {
+			delete(a.fired, key)
+		}
+	}
+	for key, w := range a.counts {
+		if now.Sub(w.start) >= a.maxWindow() {
+			delete(a.counts, key)
+		}
+	}
+}
+
+func (a *Alerter) maxWindow() time.Duration {
+	var longest time.Duration
+	for _, r := range a.rules {
+		longest = max(longest, r.Window)
+	}
+	return longest
+}
+
+// Send delivers alert to every sink, even if some fail.
+func (a *Alerter) Send(ctx context.Context, alert Alert) error {
+	var errs []error
+	for _, sink := range a.sinks {
+		if err := sink.Send(ctx, alert); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,196 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func newTestAlerter(rules ...Rule) (*Alerter, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return New(rules, 15*time.Minute).WithClock(c), c
+}
+
+func TestAlerter_Threshold(t *testing.T) {
+	a, _ := newTestAlerter(Rule{Name: "failures", Severity: SeverityWarning, Threshold: 3, Window: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if _, ok := a.Record("failures", "", "auth failures"); ok {
+			t.Fatalf("Record() #%d fired below threshold", i+1)
+		}
+	}
+	alert, ok := a.Record("failures", "", "auth failures")
+	if !ok {
+		t.Fatalf("Record() at threshold did not fire")
+	}
+	if alert.Rule != "failures" || alert.Severity != SeverityWarning || alert.DedupKey != "failures:" {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	// Occurrences spread over more than the window never fire.
+	b, now := newTestAlerter(Rule{Name: "failures", Threshold: 2, Window: time.Minute})
+	b.Record("failures", "", "")
+	now.Advance(time.Minute)
+	if _, ok := b.Record("failures", "", ""); ok {
+		t.Errorf("Record() fired across windows")
+	}
+}
+
+func TestAlerter_Dedup(t *testing.T) {
+	a, now := newTestAlerter(Rule{Name: "honeytoken", Severity: SeverityCritical, Threshold: 1, Window: time.Minute})
+
+	if _, ok := a.Record("honeytoken", "fp-1", ""); !ok {
+		t.Fatalf("first Record() did not fire")
+	}
+	if _, ok := a.Record("honeytoken", "fp-1", ""); ok {
+		t.Errorf("Record() for same subject within dedup window fired")
+	}
+	if _, ok := a.Record("honeytoken", "fp-2", ""); !ok {
+		t.Errorf("Record() for other subject did not fire")
+	}
+
+	now.Advance(15 * time.Minute)
+	if _, ok := a.Record("honeytoken", "fp-1", ""); !ok {
+		t.Errorf("Record() after dedup window did not fire")
+	}
+}
+
+func TestAlerter_UnknownRule(t *testing.T) {
+	a, _ := newTestAlerter()
+	if _, ok := a.Record("nope", "", ""); ok {
+		t.Errorf("Record() for unknown rule fired")
+	}
+}
+
+type failingSink struct{ calls int }
+
+func (s *failingSink) Send(context.Context, Alert) error {
+	s.calls++
+	return errors.New("down")
+}
+
+func TestAlerter_SendReachesAllSinks(t *testing.T) {
+	first, second := &failingSink{}, &failingSink{}
+	a := New(nil, time.Minute, first, second)
+
+	if err := a.Send(context.Background(), Alert{}); err == nil {
+		t.Errorf("Send() error = nil, want error")
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Errorf("sink calls = %d, %d, want 1, 1", first.calls, second.calls)
+	}
+}
+
+// blockingSink records alerts, holding each send until release is closed.
+type blockingSink struct {
+	started chan string
+	release chan struct{}
+}
+
+func (s *blockingSink) Send(_ context.Context, alert Alert) error {
+	s.started <- alert.DedupKey
+	<-s.release
+	return nil
+}
+
+func TestDispatcher_DropsWhenFull(t *testing.T) {
+	sink := &blockingSink{started: make(chan string, 3), release: make(chan struct{})}
+	d := NewDispatcher(New(nil, time.Minute, sink), 1, time.Second)
+
+	d.Enqueue(Alert{DedupKey: "a"})
+	<-sink.started
+	if !d.Enqueue(Alert{DedupKey: "b"}) {
+		t.Errorf("Enqueue() with room in the queue = false")
+	}
+	if d.Enqueue(Alert{DedupKey: "c"}) {
+		t.Errorf("Enqueue() with a full queue = true")
+	}
+	if d.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", d.Dropped())
+	}
+
+	close(sink.release)
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := <-sink.started; got != "b" {
+		t.Errorf("second alert sent = %q, want b", got)
+	}
+}
+
+func TestSinks(t *testing.T) {
+	alert := Alert{
+		Rule:     "honeytoken",
+		Severity: SeverityCritical,
+		Subject:  "fp-1",
+		Summary:  "honeytoken fp-1 used",
+		DedupKey: "honeytoken:fp-1",
+		Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		sink  func(url string) Sink
+		check func(t *testing.T, body map[string]any)
+	}{
+		"webhook": {
+			sink: func(url string) Sink { return &Webhook{URL: url} },
+			check: func(t *testing.T, body map[string]any) {
+				if body["rule"] != "honeytoken" || body["dedup_key"] != "honeytoken:fp-1" {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+		"slack": {
+			sink: func(url string) Sink { return &Slack{URL: url} },
+			check: func(t *testing.T, body map[string]any) {
+				if body["text"] != "[critical] honeytoken: honeytoken fp-1 used" {
+					t.Errorf("text = %v", body["text"])
+				}
+			},
+		},
+		"pagerduty": {
+			sink: func(url string) Sink { return &PagerDuty{RoutingKey: "rk", Source: "notely", URL: url} },
+			check: func(t *testing.T, body map[string]any) {
+				payload, _ := body["payload"].(map[string]any)
+				if body["routing_key"] != "rk" || body["event_action"] != "trigger" || body["dedup_key"] != "honeytoken:fp-1" ||
+					payload["severity"] != "critical" || payload["source"] != "notely" {
+					t.Errorf("body = %v", body)
+				}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var body map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("decode body: %v", err)
+				}
+			}))
+			defer srv.Close()
+
+			if err := tc.sink(srv.URL).Send(context.Background(), alert); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			tc.check(t, body)
+		})
+	}
+}
+
+func TestSink_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	if err := (&Webhook{URL: srv.URL}).Send(context.Background(), Alert{}); err == nil {
+		t.Errorf("Send() error = nil on 400")
+	}
+}
@@ -0,0 +1,72 @@
+package alerting
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Dispatcher sends alerts from a bounded queue on its own goroutine, so
+// that firing an alert never waits on a sink. Alerts that arrive while the
+// queue is full are dropped and counted rather than blocking the caller.
+type Dispatcher struct {
+	alerter *Alerter
+	timeout time.Duration
+	queue   chan Alert
+	done    chan struct{}
+	dropped atomic.Uint64
+}
+
+// NewDispatcher returns a running Dispatcher sending through a, holding at
+// most size queued alerts and giving each send up to timeout.
+func NewDispatcher(a *Alerter, size int, timeout time.Duration) *Dispatcher {
+	d := &Dispatcher{
+		alerter: a,
+		timeout: timeout,
+		queue:   make(chan Alert, size),
+		done:    make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue queues alert for sending. It reports false, and counts the
+// alert as dropped, if the queue is full.
+func (d *Dispatcher) Enqueue(alert Alert) bool {
+	select {
+	case d.queue <- alert:
+		return true
+	default:
+		d.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns the number of alerts dropped because the queue was full.
+func (d *Dispatcher) Dropped() uint64 {
+	return d.dropped.Load()
+}
+
+// Close stops accepting alerts and waits for queued ones to be sent or ctx
+// to end. Enqueue must not be called after Close.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	close(d.queue)
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for alert := range d.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+		if err := d.alerter.Send(ctx, alert); err != nil {
+			log.Printf("Couldn't deliver alert %s: %v", alert.DedupKey, err)
+		}
+		cancel()
+	}
+}
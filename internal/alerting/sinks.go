@@ -0,0 +1,94 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// PagerDutyEventsURL is PagerDuty's Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Webhook posts alerts as JSON to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *Webhook) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, s.Client, s.URL, map[string]any{
+		"rule":      alert.Rule,
+		"severity":  alert.Severity,
+		"subject":   alert.Subject,
+		"summary":   alert.Summary,
+		"dedup_key": alert.DedupKey,
+		"time":      alert.Time,
+	})
+}
+
+// Slack posts alerts to a Slack incoming webhook URL.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *Slack) Send(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, s.Client, s.URL, map[string]any{
+		"text": fmt.Sprintf("[%s] %s: %s", alert.Severity, alert.Rule, alert.Summary),
+	})
+}
+
+// PagerDuty triggers incidents through the Events API v2. Alerts with the
+// same DedupKey group into one incident. URL defaults to
+// PagerDutyEventsURL.
+type PagerDuty struct {
+	RoutingKey string
+	Source     string
+	URL        string
+	Client     *http.Client
+}
+
+func (s *PagerDuty) Send(ctx context.Context, alert Alert) error {
+	url := s.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	return postJSON(ctx, s.Client, url, map[string]any{
+		"routing_key":  s.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.DedupKey,
+		"payload": map[string]any{
+			"summary":   alert.Summary,
+			"source":    s.Source,
+			"severity":  alert.Severity,
+			"timestamp": alert.Time,
+			"component": alert.Rule,
+		},
+	})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	dat, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(dat))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
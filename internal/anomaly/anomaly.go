@@ -0,0 +1,156 @@
+// Package anomaly flags API key usage that departs sharply from the key's
+// own history: bursts of volume, endpoints it has never called, and
+// addresses it has never been used from.
+package anomaly
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Kind names the way usage was anomalous.
+type Kind string
+
+const (
+	KindVolume      Kind = "volume"
+	KindNewEndpoint Kind = "new_endpoint"
+	KindNewAddress  Kind = "new_address"
+)
+
+// Usage is one authenticated request.
+type Usage struct {
+	Key      string
+	Endpoint string
+	Addr     string
+	Time     time.Time
+}
+
+// Anomaly is usage that deviates from the key's baseline.
+type Anomaly struct {
+	Key    string
+	Kind   Kind
+	Detail string
+}
+
+// Config tunes a Detector. Zero fields take the defaults below.
+type Config struct {
+	// WarmUp is how many requests a key must have made before it is judged
+	// against its baseline. Default 100.
+	WarmUp int
+	// VolumeFactor is how many times its per-minute baseline a key must
+	// exceed in one minute to be flagged. Default 5.
+	VolumeFactor float64
+	// MinVolume is the fewest requests in a minute that can be flagged, so
+	// quiet keys aren't flagged for a handful of calls. Default 60.
+	MinVolume int
+	// Smoothing is the weight of the latest minute in the moving average
+	// baseline. Default 0.1.
+	Smoothing float64
+	// MaxTracked caps the endpoints and addresses remembered per key.
+	// Novelty isn't flagged for a kind once its cap is reached. Default 1000.
+	MaxTracked int
+}
+
+// Detector keeps a baseline per key. It is safe for concurrent use.
+type Detector struct {
+	cfg Config
+
+	mu   sync.Mutex
+	keys map[string]*profile
+}
+
+type profile struct {
+	total     int
+	endpoints map[string]struct{}
+	addrs     map[string]struct{}
+
+	minute   time.Time
+	count    int
+	baseline float64
+	flagged  bool
+}
+
+// NewDetector returns a Detector with no history.
+func NewDetector(cfg Config) *Detector {
+	if cfg.WarmUp == 0 {
+		cfg.WarmUp = 100
+	}
+	if cfg.VolumeFactor == 0 {
+		cfg.VolumeFactor = 5
+	}
+	if cfg.MinVolume == 0 {
+		cfg.MinVolume = 60
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = 0.1
+	}
+	if cfg.MaxTracked == 0 {
+		cfg.MaxTracked = 1000
+	}
+	return &Detector{cfg: cfg, keys: map[string]*profile{}}
+}
+
+// Observe records u and returns any anomalies it shows. A volume anomaly
+// is reported at most once per key per minute.
+func (d *Detector) Observe(u Usage) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.keys[u.Key]
+	if !ok {
+		p = &profile{endpoints: map[string]struct{}{}, addrs: map[string]struct{}{}}
+		d.keys[u.Key] = p
+	}
+	judged := p.total >= d.cfg.WarmUp
+	p.total++
+
+	var found []Anomaly
+	p.advance(u.Time.Truncate(time.Minute), d.cfg.Smoothing)
+	p.count++
+	limit := max(float64(d.cfg.MinVolume), d.cfg.VolumeFactor*p.baseline)
+	if judged && !p.flagged && float64(p.count) > limit {
+		p.flagged = true
+		found = append(found, Anomaly{
+			Key:    u.Key,
+			Kind:   KindVolume,
+			Detail: fmt.Sprintf("%d requests this minute, baseline %.1f", p.count, p.baseline),
+		})
+	}
+	if d.novel(p.endpoints, u.Endpoint) && judged {
+		found = append(found, Anomaly{Key: u.Key, Kind: KindNewEndpoint, Detail: u.Endpoint})
+	}
+	if d.novel(p.addrs, u.Addr) && judged {
+		found = append(found, Anomaly{Key: u.Key, Kind: KindNewAddress, Detail: u.Addr})
+	}
+	return found
+}
+
+// novel adds v to seen and reports whether it wasn't there before. Once
+// seen is full nothing is novel.
+func (d *Detector) novel(seen map[string]struct{}, v string) bool {
+	if _, ok := seen[v]; ok || len(seen) >= d.cfg.MaxTracked {
+		return false
+	}
+	seen[v] = struct{}{}
+	return true
+}
+
+// advance folds finished minutes, including idle ones, into the baseline.
+func (p *profile) advance(minute time.Time, smoothing float64) {
+	if p.minute.IsZero() {
+		p.minute = minute
+		return
+	}
+	if !minute.After(p.minute) {
+		return
+	}
+	idle := int(minute.Sub(p.minute)/time.Minute) - 1
+	p.baseline += smoothing * (float64(p.count) - p.baseline)
+	for i := 0; i < idle && p.baseline > 0.01; i++ {
+		p.baseline -= smoothing * p.baseline
+	}
+	p.minute = minute
+	p.count = 0
+	p.flagged = false
+}
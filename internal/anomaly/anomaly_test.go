@@ -0,0 +1,109 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// warmUp sends n requests per minute for minutes minutes from one endpoint
+// and address, returning the time after the last one.
+func warmUp(t *testing.T, d *Detector, perMinute, minutes int) time.Time {
+	t.Helper()
+	now := start
+	for m := 0; m < minutes; m++ {
+		for i := 0; i < perMinute; i++ {
+			if found := d.Observe(Usage{Key: "k", Endpoint: "GET /v1/notes", Addr: "1.2.3.4", Time: now}); len(found) != 0 {
+				t.Fatalf("anomaly during warm-up: %v", found)
+			}
+		}
+		now = now.Add(time.Minute)
+	}
+	return now
+}
+
+func kinds(found []Anomaly) []Kind {
+	var ks []Kind
+	for _, a := range found {
+		ks = append(ks, a.Kind)
+	}
+	return ks
+}
+
+func TestDetector_Volume(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 50, MinVolume: 10})
+	now := warmUp(t, d, 5, 30)
+
+	var flagged []Anomaly
+	for i := 0; i < 40; i++ {
+		flagged = append(flagged, d.Observe(Usage{Key: "k", Endpoint: "GET /v1/notes", Addr: "1.2.3.4", Time: now})...)
+	}
+	if len(flagged) != 1 || flagged[0].Kind != KindVolume {
+		t.Fatalf("burst anomalies = %v, want one volume anomaly", flagged)
+	}
+
+	now = now.Add(time.Minute)
+	if found := d.Observe(Usage{Key: "k", Endpoint: "GET /v1/notes", Addr: "1.2.3.4", Time: now}); len(found) != 0 {
+		t.Errorf("anomalies in quiet minute after burst = %v", found)
+	}
+}
+
+func TestDetector_Novelty(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 50})
+	now := warmUp(t, d, 5, 20)
+
+	found := d.Observe(Usage{Key: "k", Endpoint: "DELETE /v1/keys/{keyID}", Addr: "9.9.9.9", Time: now})
+	if ks := kinds(found); len(ks) != 2 || ks[0] != KindNewEndpoint || ks[1] != KindNewAddress {
+		t.Fatalf("anomalies = %v, want new endpoint and new address", found)
+	}
+	if found := d.Observe(Usage{Key: "k", Endpoint: "DELETE /v1/keys/{keyID}", Addr: "9.9.9.9", Time: now}); len(found) != 0 {
+		t.Errorf("anomalies on repeat = %v, want none", found)
+	}
+}
+
+func TestDetector_NoAnomaliesDuringWarmUp(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 50, MinVolume: 1})
+	for i := 0; i < 49; i++ {
+		found := d.Observe(Usage{Key: "k", Endpoint: "GET /" + string(rune('a'+i%26)), Addr: "1.2.3.4", Time: start})
+		if len(found) != 0 {
+			t.Fatalf("request %d anomalies = %v, want none before warm-up", i, found)
+		}
+	}
+}
+
+func TestDetector_KeysAreIndependent(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 10})
+	warmUp(t, d, 5, 4)
+
+	if found := d.Observe(Usage{Key: "other", Endpoint: "POST /v1/keys", Addr: "9.9.9.9", Time: start}); len(found) != 0 {
+		t.Errorf("anomalies for new key = %v, want none", found)
+	}
+}
+
+func TestDetector_MaxTracked(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 1, MaxTracked: 2})
+	d.Observe(Usage{Key: "k", Endpoint: "a", Addr: "1", Time: start})
+	if found := d.Observe(Usage{Key: "k", Endpoint: "b", Addr: "1", Time: start}); len(found) != 1 {
+		t.Fatalf("anomalies = %v, want new endpoint", found)
+	}
+	if found := d.Observe(Usage{Key: "k", Endpoint: "c", Addr: "1", Time: start}); len(found) != 0 {
+		t.Errorf("anomalies past MaxTracked = %v, want none", found)
+	}
+}
+
+func TestDetector_IdleMinutesDecayBaseline(t *testing.T) {
+	d := NewDetector(Config{WarmUp: 10, MinVolume: 1, VolumeFactor: 2, Smoothing: 0.5})
+	now := warmUp(t, d, 10, 10)
+
+	// After a long idle stretch the baseline has decayed, so a moderate
+	// burst stands out.
+	now = now.Add(time.Hour)
+	var found []Anomaly
+	for i := 0; i < 5; i++ {
+		found = append(found, d.Observe(Usage{Key: "k", Endpoint: "GET /v1/notes", Addr: "1.2.3.4", Time: now})...)
+	}
+	if len(found) != 1 || found[0].Kind != KindVolume {
+		t.Errorf("anomalies after idle = %v, want one volume anomaly", found)
+	}
+}
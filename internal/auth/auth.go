@@ -7,17 +7,202 @@ import (
 )
 
 var ErrNoAuthHeaderIncluded = errors.New("no authorization header included")
+var ErrMultipleAuthHeaders = errors.New("multiple authorization headers included")
+var ErrMalformedAuthHeader = errors.New("malformed authorization header")
+var ErrAuthHeaderTooLong = errors.New("authorization header too long")
+var ErrInvalidAuthHeaderChars = errors.New("invalid characters in authorization header")
 
-// GetAPIKey -
-func GetAPIKey(headers http.Header) (string, error) {
-	authHeader := headers.Get("Authorization")
+// DefaultMaxHeaderLength bounds the Authorization header value. Issued keys
+// are well under 100 bytes; anything near this limit is not a real key.
+const DefaultMaxHeaderLength = 512
+
+// Errors returned only in strict parsing mode.
+var (
+	ErrEmptyAPIKey          = errors.New("empty api key")
+	ErrUnexpectedWhitespace = errors.New("unexpected whitespace in authorization header")
+	ErrTrailingAuthData     = errors.New("unexpected data after api key")
+)
+
+// ParseMode selects how strictly GetAPIKey validates the header value.
+type ParseMode int
+
+const (
+	// ParseLenient is the legacy behavior: the value is split on single
+	// spaces and the second field is returned, even if it is empty.
+	ParseLenient ParseMode = iota
+	// ParseStrict requires exactly "ApiKey <key>" with a non-empty key and
+	// no surrounding or trailing data.
+	ParseStrict
+)
+
+// MultipleHeaderPolicy decides which Authorization header GetAPIKey reads
+// when a request carries more than one.
+type MultipleHeaderPolicy int
+
+const (
+	// UseFirstHeader reads the first header and ignores the rest.
+	UseFirstHeader MultipleHeaderPolicy = iota
+	// UseLastHeader reads the last header and ignores the rest.
+	UseLastHeader
+	// RejectMultipleHeaders fails with ErrMultipleAuthHeaders.
+	RejectMultipleHeaders
+)
+
+type options struct {
+	multipleHeaders MultipleHeaderPolicy
+	mode            ParseMode
+	maxLength       int
+}
+
+// Option configures GetAPIKey. Options are applied by value so that parsing
+// doesn't force the option set onto the heap.
+type Option interface {
+	apply(options) options
+}
+
+type multipleHeaderOption MultipleHeaderPolicy
+
+func (p multipleHeaderOption) apply(o options) options {
+	o.multipleHeaders = MultipleHeaderPolicy(p)
+	return o
+}
+
+type parseModeOption ParseMode
+
+func (m parseModeOption) apply(o options) options {
+	o.mode = ParseMode(m)
+	return o
+}
+
+// WithMultipleHeaderPolicy sets how repeated Authorization headers are
+// handled. The default is UseFirstHeader.
+func WithMultipleHeaderPolicy(p MultipleHeaderPolicy) Option {
+	return multipleHeaderOption(p)
+}
+
+type maxLengthOption int
+
+func (n maxLengthOption) apply(o options) options {
+	o.maxLength = int(n)
+	return o
+}
+
+// WithMaxHeaderLength sets the longest accepted header value in bytes. The
+// default is DefaultMaxHeaderLength.
+func WithMaxHeaderLength(n int) Option {
+	return maxLengthOption(n)
+}
+
+// WithParseMode sets the header parsing mode. The default is ParseLenient.
+func WithParseMode(m ParseMode) Option {
+	return parseModeOption(m)
+}
+
+// GetAPIKey extracts the key from an "ApiKey <key>" Authorization header.
+// It runs on every authenticated request and does not allocate.
+func GetAPIKey(headers http.Header, opts ...Option) (string, error) {
+	o := options{maxLength: DefaultMaxHeaderLength}
+	for _, opt := range opts {
+		o = opt.apply(o)
+	}
+
+	authHeader, err := authorizationHeader(headers, o.multipleHeaders)
+	if err != nil {
+		return "", err
+	}
 	if authHeader == "" {
 		return "", ErrNoAuthHeaderIncluded
 	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "ApiKey" {
-		return "", errors.New("malformed authorization header")
+	if err := checkHeaderBytes(authHeader, o.maxLength); err != nil {
+		return "", err
+	}
+	key, err := parseHeader(authHeader, o.mode)
+	if err != nil {
+		return "", err
+	}
+	if err := VerifyKeyChecksum(key); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// checkHeaderBytes rejects values no legitimate client sends: overlong
+// values, control characters other than tab (including NUL), and non-ASCII
+// bytes in the scheme. net/http already refuses CR and LF, but headers can
+// also arrive from proxies and tests that bypass it.
+func checkHeaderBytes(authHeader string, maxLength int) error {
+	if len(authHeader) > maxLength {
+		return ErrAuthHeaderTooLong
+	}
+	inScheme := true
+	for i := 0; i < len(authHeader); i++ {
+		c := authHeader[i]
+		switch {
+		case c == ' ':
+			inScheme = false
+		case c < ' ' && c != '\t', c == 0x7f:
+			return ErrInvalidAuthHeaderChars
+		case c >= 0x80 && inScheme:
+			return ErrInvalidAuthHeaderChars
+		}
+	}
+	return nil
+}
+
+func parseHeader(authHeader string, mode ParseMode) (string, error) {
+	if mode == ParseStrict {
+		return parseStrict(authHeader)
+	}
+	// Equivalent to strings.Split(authHeader, " ")[1] with the scheme check,
+	// without allocating the slice.
+	scheme, key, ok := strings.Cut(authHeader, " ")
+	if !ok || scheme != "ApiKey" {
+		return "", ErrMalformedAuthHeader
 	}
+	if i := strings.IndexByte(key, ' '); i >= 0 {
+		key = key[:i]
+	}
+
+	return key, nil
+}
+
+func parseStrict(authHeader string) (string, error) {
+	if strings.TrimLeft(authHeader, " \t") != authHeader {
+		return "", ErrUnexpectedWhitespace
+	}
+	scheme, key, ok := strings.Cut(authHeader, " ")
+	if !ok || scheme != "ApiKey" {
+		return "", ErrMalformedAuthHeader
+	}
+	if key == "" {
+		return "", ErrEmptyAPIKey
+	}
+	if strings.TrimSpace(key) != key {
+		return "", ErrUnexpectedWhitespace
+	}
+	if strings.ContainsAny(key, " \t") {
+		return "", ErrTrailingAuthData
+	}
+
+	return key, nil
+}
 
-	return splitAuth[1], nil
+func authorizationHeader(headers http.Header, policy MultipleHeaderPolicy) (string, error) {
+	values := headers.Values("Authorization")
+	switch {
+	case len(values) == 0:
+		return "", nil
+	case len(values) == 1:
+		return values[0], nil
+	}
+
+	switch policy {
+	case UseLastHeader:
+		return values[len(values)-1], nil
+	case RejectMultipleHeaders:
+		return "", ErrMultipleAuthHeaders
+	default:
+		return values[0], nil
+	}
 }
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -195,3 +196,240 @@ func TestGetAPIKey_ValidKeyExtractionFromComplexHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAPIKey_MultipleHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        MultipleHeaderPolicy
+		expectedKey   string
+		expectedError error
+	}{
+		{
+			name:        "use first header",
+			policy:      UseFirstHeader,
+			expectedKey: "first-key",
+		},
+		{
+			name:        "use last header",
+			policy:      UseLastHeader,
+			expectedKey: "last-key",
+		},
+		{
+			name:          "reject multiple headers",
+			policy:        RejectMultipleHeaders,
+			expectedKey:   "",
+			expectedError: ErrMultipleAuthHeaders,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make(http.Header)
+			headers.Add("Authorization", "ApiKey first-key")
+			headers.Add("Authorization", "ApiKey last-key")
+
+			key, err := GetAPIKey(headers, WithMultipleHeaderPolicy(tt.policy))
+
+			if key != tt.expectedKey {
+				t.Errorf("GetAPIKey() key = %v, want %v", key, tt.expectedKey)
+			}
+			if err != tt.expectedError {
+				t.Errorf("GetAPIKey() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestGetAPIKey_SingleHeaderIgnoresPolicy(t *testing.T) {
+	for _, policy := range []MultipleHeaderPolicy{UseFirstHeader, UseLastHeader, RejectMultipleHeaders} {
+		headers := make(http.Header)
+		headers.Set("Authorization", "ApiKey only-key")
+
+		key, err := GetAPIKey(headers, WithMultipleHeaderPolicy(policy))
+
+		if err != nil {
+			t.Errorf("GetAPIKey() policy %d unexpected error = %v", policy, err)
+		}
+		if key != "only-key" {
+			t.Errorf("GetAPIKey() policy %d key = %v, want only-key", policy, key)
+		}
+	}
+}
+
+func TestGetAPIKey_StrictMode(t *testing.T) {
+	tests := []struct {
+		name          string
+		authHeader    string
+		expectedKey   string
+		expectedError error
+	}{
+		{
+			name:        "valid API key",
+			authHeader:  "ApiKey test-api-key-123",
+			expectedKey: "test-api-key-123",
+		},
+		{
+			name:          "missing API key value",
+			authHeader:    "ApiKey",
+			expectedError: ErrMalformedAuthHeader,
+		},
+		{
+			name:          "wrong prefix",
+			authHeader:    "Bearer test-api-key-123",
+			expectedError: ErrMalformedAuthHeader,
+		},
+		{
+			name:          "empty value",
+			authHeader:    "ApiKey ",
+			expectedError: ErrEmptyAPIKey,
+		},
+		{
+			name:          "double space before key",
+			authHeader:    "ApiKey  test-api-key",
+			expectedError: ErrUnexpectedWhitespace,
+		},
+		{
+			name:          "leading whitespace",
+			authHeader:    " ApiKey test-api-key",
+			expectedError: ErrUnexpectedWhitespace,
+		},
+		{
+			name:          "trailing whitespace",
+			authHeader:    "ApiKey test-api-key\t",
+			expectedError: ErrUnexpectedWhitespace,
+		},
+		{
+			name:          "trailing garbage",
+			authHeader:    "ApiKey test-key extra-data",
+			expectedError: ErrTrailingAuthData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make(http.Header)
+			headers.Set("Authorization", tt.authHeader)
+
+			key, err := GetAPIKey(headers, WithParseMode(ParseStrict))
+
+			if key != tt.expectedKey {
+				t.Errorf("GetAPIKey() key = %v, want %v", key, tt.expectedKey)
+			}
+			if err != tt.expectedError {
+				t.Errorf("GetAPIKey() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func BenchmarkGetAPIKey(b *testing.B) {
+	key, err := GenerateAPIKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	benchmarks := []struct {
+		name   string
+		header string
+		opts   []Option
+	}{
+		{"legacy key", "ApiKey super_test_4eC39HqLyjWDarjtT1zdp7dc", nil},
+		{"checksummed key", "ApiKey " + key, nil},
+		{"strict mode", "ApiKey " + key, []Option{WithParseMode(ParseStrict), WithMultipleHeaderPolicy(RejectMultipleHeaders)}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			headers := make(http.Header)
+			headers.Set("Authorization", bm.header)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := GetAPIKey(headers, bm.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAPIKey_DoesNotAllocate(t *testing.T) {
+	key, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := make(http.Header)
+	headers.Set("Authorization", "ApiKey "+key)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = GetAPIKey(headers, WithMultipleHeaderPolicy(RejectMultipleHeaders), WithParseMode(ParseStrict))
+	})
+	if allocs != 0 {
+		t.Errorf("GetAPIKey() allocated %v times per call, want 0", allocs)
+	}
+}
+
+func TestGetAPIKey_HostileHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		authHeader    string
+		opts          []Option
+		expectedKey   string
+		expectedError error
+	}{
+		{
+			name:          "NUL byte in key",
+			authHeader:    "ApiKey test\x00key",
+			expectedError: ErrInvalidAuthHeaderChars,
+		},
+		{
+			name:          "escape sequence",
+			authHeader:    "ApiKey \x1b[31mtest-key",
+			expectedError: ErrInvalidAuthHeaderChars,
+		},
+		{
+			name:          "DEL character",
+			authHeader:    "ApiKey test-key\x7f",
+			expectedError: ErrInvalidAuthHeaderChars,
+		},
+		{
+			name:          "non-ASCII scheme",
+			authHeader:    "ApiKéy test-key",
+			expectedError: ErrInvalidAuthHeaderChars,
+		},
+		{
+			name:          "overlong value",
+			authHeader:    "ApiKey " + strings.Repeat("a", DefaultMaxHeaderLength),
+			expectedError: ErrAuthHeaderTooLong,
+		},
+		{
+			name:          "custom max length",
+			authHeader:    "ApiKey test-api-key-123",
+			opts:          []Option{WithMaxHeaderLength(10)},
+			expectedError: ErrAuthHeaderTooLong,
+		},
+		{
+			name:        "value at max length",
+			authHeader:  "ApiKey " + strings.Repeat("a", DefaultMaxHeaderLength-len("ApiKey ")),
+			expectedKey: strings.Repeat("a", DefaultMaxHeaderLength-len("ApiKey ")),
+		},
+		{
+			name:        "tab is allowed through to the parser",
+			authHeader:  "ApiKey test-key\textra",
+			expectedKey: "test-key\textra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{"Authorization": {tt.authHeader}}
+
+			key, err := GetAPIKey(headers, tt.opts...)
+
+			if key != tt.expectedKey {
+				t.Errorf("GetAPIKey() key = %q, want %q", key, tt.expectedKey)
+			}
+			if err != tt.expectedError {
+				t.Errorf("GetAPIKey() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
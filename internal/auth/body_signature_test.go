@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestVerifyBody(t *testing.T) {
+	secret := []byte("shared-secret")
+	body := `{"note":"hello"}`
+
+	tests := []struct {
+		name          string
+		body          string
+		signature     string
+		expectedError error
+	}{
+		{"valid signature", body, SignBody([]byte(body), secret), nil},
+		{"tampered body", `{"note":"HELLO"}`, SignBody([]byte(body), secret), ErrBodySignatureMismatch},
+		{"wrong secret", body, SignBody([]byte(body), []byte("other")), ErrBodySignatureMismatch},
+		{"truncated body", body[:5], SignBody([]byte(body), secret), ErrBodySignatureMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// OneByteReader forces the MAC to be computed incrementally.
+			rc, err := VerifyBody(io.NopCloser(iotest.OneByteReader(strings.NewReader(tt.body))), secret, tt.signature)
+			if err != nil {
+				t.Fatalf("VerifyBody() error = %v", err)
+			}
+			got, err := io.ReadAll(rc)
+			if err != tt.expectedError {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.expectedError)
+			}
+			if string(got) != tt.body {
+				t.Errorf("ReadAll() = %q, want %q", got, tt.body)
+			}
+			if _, err := rc.Read(make([]byte, 1)); tt.expectedError != nil && err != tt.expectedError {
+				t.Errorf("Read() after mismatch error = %v, want sticky %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestVerifyBody_BadHeader(t *testing.T) {
+	tests := []struct {
+		signature     string
+		expectedError error
+	}{
+		{"", ErrMissingBodySignature},
+		{"md5=abc", ErrMissingBodySignature},
+		{"sha256=not-hex", ErrBodySignatureMismatch},
+	}
+
+	for _, tt := range tests {
+		_, err := VerifyBody(io.NopCloser(strings.NewReader("")), []byte("s"), tt.signature)
+		if err != tt.expectedError {
+			t.Errorf("VerifyBody(%q) error = %v, want %v", tt.signature, err, tt.expectedError)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const modulePath = "github.com/bootdotdev/learn-cicd-starter"
+
+// TestStandardLibraryOnly keeps the packages other services build against
+// free of third-party dependencies, so that depending on them for header
+// parsing and static keys doesn't pull in the server's database driver or
+// router. Integrations that need a dependency belong in their own package.
+func TestStandardLibraryOnly(t *testing.T) {
+	root := filepath.Join("..", "..")
+	seen := map[string]bool{}
+	var walk func(pkg string, from []string)
+	walk = func(pkg string, from []string) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		for _, imp := range imports(t, filepath.Join(root, strings.TrimPrefix(pkg, modulePath))) {
+			chain := append(from[:len(from):len(from)], imp)
+			switch {
+			case strings.HasPrefix(imp, modulePath+"/"):
+				walk(imp, chain)
+			case strings.Contains(strings.SplitN(imp, "/", 2)[0], "."):
+				t.Errorf("non-standard dependency: %s", strings.Join(chain, " -> "))
+			}
+		}
+	}
+	for _, pkg := range []string{"internal/auth", "client", "authtest"} {
+		walk(modulePath+"/"+pkg, []string{modulePath + "/" + pkg})
+	}
+}
+
+// imports lists the imports of the non-test Go files in dir.
+func imports(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.ImportsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, spec := range f.Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
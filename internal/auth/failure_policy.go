@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// FailurePolicy decides what happens to a request when the key store can't
+// be reached.
+type FailurePolicy int
+
+const (
+	// FailClosed rejects the request.
+	FailClosed FailurePolicy = iota
+	// FailOpen admits the request if the key was recently verified.
+	FailOpen
+)
+
+func (p FailurePolicy) String() string {
+	if p == FailOpen {
+		return "open"
+	}
+	return "closed"
+}
+
+// ParseFailurePolicy parses "open" or "closed". An empty string yields def.
+func ParseFailurePolicy(s string, def FailurePolicy) (FailurePolicy, error) {
+	switch s {
+	case "":
+		return def, nil
+	case "open":
+		return FailOpen, nil
+	case "closed":
+		return FailClosed, nil
+	}
+	return def, fmt.Errorf("unknown failure policy %q", s)
+}
+
+// RouteClass groups routes that share a failure policy.
+type RouteClass string
+
+const (
+	RouteClassRead  RouteClass = "read"
+	RouteClassWrite RouteClass = "write"
+)
+
+// RouteClassOf classifies a request by method: safe methods are reads,
+// everything else is a write.
+func RouteClassOf(r *http.Request) RouteClass {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RouteClassRead
+	}
+	return RouteClassWrite
+}
+
+// FailurePolicies holds the policy per route class.
+type FailurePolicies map[RouteClass]FailurePolicy
+
+// For returns the policy for class, failing closed if none is set.
+func (p FailurePolicies) For(class RouteClass) FailurePolicy {
+	return p[class]
+}
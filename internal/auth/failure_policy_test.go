@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseFailurePolicy(t *testing.T) {
+	tests := []struct {
+		value     string
+		expected  FailurePolicy
+		expectErr bool
+	}{
+		{"", FailOpen, false},
+		{"open", FailOpen, false},
+		{"closed", FailClosed, false},
+		{"sideways", FailOpen, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFailurePolicy(tt.value, FailOpen)
+		if got != tt.expected || (err != nil) != tt.expectErr {
+			t.Errorf("ParseFailurePolicy(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestFailurePolicies_For(t *testing.T) {
+	p := FailurePolicies{RouteClassRead: FailOpen}
+
+	if got := p.For(RouteClassOf(httptest.NewRequest("GET", "/v1/notes", nil))); got != FailOpen {
+		t.Errorf("For(read) = %v, want open", got)
+	}
+	if got := p.For(RouteClassOf(httptest.NewRequest("POST", "/v1/notes", nil))); got != FailClosed {
+		t.Errorf("For(write) = %v, want closed", got)
+	}
+}
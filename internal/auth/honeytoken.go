@@ -0,0 +1,26 @@
+package auth
+
+import "strings"
+
+// Honeytokens is a set of decoy keys identified by Fingerprint, so the decoy
+// values themselves never need to appear in configuration. A decoy key is
+// never issued to anyone; any request carrying one indicates a leak.
+type Honeytokens map[string]struct{}
+
+// ParseHoneytokens builds a set from a comma-separated list of fingerprints.
+func ParseHoneytokens(s string) Honeytokens {
+	h := Honeytokens{}
+	for _, fp := range strings.Split(s, ",") {
+		fp = strings.TrimSpace(fp)
+		if fp != "" {
+			h[fp] = struct{}{}
+		}
+	}
+	return h
+}
+
+// Contains reports whether key is one of the decoys.
+func (h Honeytokens) Contains(key string) bool {
+	_, ok := h[Fingerprint(key)]
+	return ok
+}
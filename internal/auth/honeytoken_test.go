@@ -0,0 +1,21 @@
+package auth
+
+import "testing"
+
+func TestHoneytokens(t *testing.T) {
+	decoy := "sbx_live_decoy"
+	h := ParseHoneytokens(" " + Fingerprint(decoy) + ",, " + Fingerprint("other-decoy"))
+
+	if len(h) != 2 {
+		t.Errorf("ParseHoneytokens() len = %d, want 2", len(h))
+	}
+	if !h.Contains(decoy) {
+		t.Errorf("Contains(%q) = false, want true", decoy)
+	}
+	if h.Contains("real-key") {
+		t.Errorf("Contains(%q) = true, want false", "real-key")
+	}
+	if ParseHoneytokens("").Contains("") {
+		t.Errorf("empty set should not contain the empty key")
+	}
+}
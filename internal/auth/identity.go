@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// PrincipalType says what kind of caller an Identity represents.
+type PrincipalType string
+
+const (
+	PrincipalUser    PrincipalType = "user"
+	PrincipalService PrincipalType = "service"
+	PrincipalRunner  PrincipalType = "runner"
+)
+
+// Identity is the authenticated caller. Every verification path produces
+// one, and downstream code should rely on it rather than on the credential
+// that was presented.
+type Identity struct {
+	ID     string        `json:"id"`
+	Type   PrincipalType `json:"type"`
+	Tenant string        `json:"tenant"`
+	Scopes []string      `json:"scopes"`
+	// Attributes carries path-specific facts, e.g. AttrHoneytoken.
+	Attributes map[string]string `json:"attributes"`
+	// CredentialID is the Fingerprint of the credential used to
+	// authenticate, never the credential itself.
+	CredentialID string `json:"credential_id"`
+}
+
+// Well-known Identity.Attributes keys.
+const (
+	// AttrHoneytoken is set to "true" on decoy identities.
+	AttrHoneytoken = "honeytoken"
+	// AttrStale is set to "true" when the identity came from a cached lookup
+	// because the key store was unavailable.
+	AttrStale = "stale"
+	// AttrPrimaryKey is set to "true" when the caller authenticated with the
+	// key issued at signup rather than a managed key.
+	AttrPrimaryKey = "primary_key"
+)
+
+// HasScope reports whether the identity was granted scope.
+func (i Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope)
+}
+
+// Attr returns the attribute value for key, or "".
+func (i Identity) Attr(key string) string {
+	return i.Attributes[key]
+}
+
+type identityContextKey struct{}
+
+// NewContext returns a copy of ctx carrying identity.
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+// FromContext returns the identity stored by NewContext.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(Identity)
+	return identity, ok
+}
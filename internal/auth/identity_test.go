@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIdentity_Context(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Errorf("FromContext() on empty context ok = true")
+	}
+
+	want := Identity{
+		ID:         "user-1",
+		Type:       PrincipalUser,
+		Scopes:     []string{"notes:read"},
+		Attributes: map[string]string{AttrHoneytoken: "true"},
+	}
+	got, ok := FromContext(NewContext(context.Background(), want))
+	if !ok {
+		t.Fatalf("FromContext() ok = false")
+	}
+	if got.ID != want.ID || got.Type != want.Type || got.Attr(AttrHoneytoken) != "true" {
+		t.Errorf("FromContext() = %+v, want %+v", got, want)
+	}
+}
+
+func TestIdentity_HasScope(t *testing.T) {
+	i := Identity{Scopes: []string{"notes:read", "notes:write"}}
+
+	if !i.HasScope("notes:write") {
+		t.Errorf("HasScope(notes:write) = false")
+	}
+	if i.HasScope("keys:manage") {
+		t.Errorf("HasScope(keys:manage) = true")
+	}
+	if (Identity{}).HasScope("") {
+		t.Errorf("empty identity HasScope(\"\") = true")
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() unexpected error = %v", err)
+	}
+	if !strings.HasPrefix(key, KeyPrefix) {
+		t.Errorf("GenerateAPIKey() = %v, want prefix %v", key, KeyPrefix)
+	}
+	if err := VerifyKeyChecksum(key); err != nil {
+		t.Errorf("VerifyKeyChecksum() on generated key error = %v", err)
+	}
+
+	other, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() unexpected error = %v", err)
+	}
+	if key == other {
+		t.Errorf("GenerateAPIKey() returned the same key twice")
+	}
+}
+
+func TestFormatAPIKey(t *testing.T) {
+	secret := make([]byte, 32)
+	key := FormatAPIKey(secret)
+	if key != FormatAPIKey(secret) {
+		t.Errorf("FormatAPIKey() is not deterministic")
+	}
+	if !strings.HasPrefix(key, KeyPrefix) || len(key) != len(KeyPrefix)+keySecretLen+keyChecksumLen {
+		t.Errorf("FormatAPIKey() = %v", key)
+	}
+	if err := VerifyKeyChecksum(key); err != nil {
+		t.Errorf("VerifyKeyChecksum() on formatted key error = %v", err)
+	}
+}
+
+func TestVerifyKeyChecksum(t *testing.T) {
+	valid, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() unexpected error = %v", err)
+	}
+	typo := []byte(valid)
+	if typo[len(KeyPrefix)] == 'a' {
+		typo[len(KeyPrefix)] = 'b'
+	} else {
+		typo[len(KeyPrefix)] = 'a'
+	}
+
+	tests := []struct {
+		name          string
+		key           string
+		expectedError error
+	}{
+		{"valid key", valid, nil},
+		{"legacy key without prefix", "super_test_51234567890abcdef", nil},
+		{"typo in secret", string(typo), ErrInvalidKeyChecksum},
+		{"truncated key", valid[:len(valid)-1], ErrInvalidKeyChecksum},
+		{"prefix only", KeyPrefix, ErrInvalidKeyChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := VerifyKeyChecksum(tt.key); err != tt.expectedError {
+				t.Errorf("VerifyKeyChecksum() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestGetAPIKey_RejectsBadChecksum(t *testing.T) {
+	valid, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() unexpected error = %v", err)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Authorization", "ApiKey "+valid)
+	key, err := GetAPIKey(headers)
+	if err != nil || key != valid {
+		t.Errorf("GetAPIKey() = %v, %v, want %v, nil", key, err, valid)
+	}
+
+	headers.Set("Authorization", "ApiKey "+valid[:len(valid)-2])
+	key, err = GetAPIKey(headers)
+	if err != ErrInvalidKeyChecksum || key != "" {
+		t.Errorf("GetAPIKey() = %v, %v, want \"\", %v", key, err, ErrInvalidKeyChecksum)
+	}
+}
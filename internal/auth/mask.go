@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	fingerprintLen = 12
+	maskVisibleLen = 4
+)
+
+// Fingerprint returns a short, stable identifier for key that is safe to
+// log or store. Equal keys always produce equal fingerprints.
+func Fingerprint(key string) string {
+	return FingerprintFromHash(HashKey(key))
+}
+
+// HashKey returns the hex SHA-256 of key, for storing keys at rest.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FingerprintFromHash returns the Fingerprint of the key whose HashKey is
+// hash, for when only the stored hash is at hand.
+func FingerprintFromHash(hash string) string {
+	return hash[:fingerprintLen]
+}
+
+// Mask returns a display form of key that keeps the issuer prefix and the
+// last few characters, e.g. "sbx_live_…abc4". Keys too short to show a
+// suffix safely are masked entirely.
+func Mask(key string) string {
+	prefix := ""
+	if strings.HasPrefix(key, KeyPrefix) {
+		prefix = KeyPrefix
+	}
+	secret := key[len(prefix):]
+	if len(secret) < 3*maskVisibleLen {
+		return prefix + "…"
+	}
+	return prefix + "…" + secret[len(secret)-maskVisibleLen:]
+}
@@ -0,0 +1,48 @@
+package auth
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("test-api-key-123")
+	if len(a) != fingerprintLen {
+		t.Errorf("Fingerprint() length = %d, want %d", len(a), fingerprintLen)
+	}
+	if a != Fingerprint("test-api-key-123") {
+		t.Errorf("Fingerprint() is not stable")
+	}
+	if a == Fingerprint("test-api-key-124") {
+		t.Errorf("Fingerprint() collided for different keys")
+	}
+}
+
+func TestHashKey(t *testing.T) {
+	hash := HashKey("abc")
+	if hash != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Errorf("HashKey() = %v", hash)
+	}
+	if FingerprintFromHash(hash) != Fingerprint("abc") {
+		t.Errorf("FingerprintFromHash(HashKey(k)) != Fingerprint(k)")
+	}
+}
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      string
+		expected string
+	}{
+		{"prefixed key", KeyPrefix + "0123456789abcdef0123", KeyPrefix + "…0123"},
+		{"legacy key", "super_test_51234567890abcdef", "…cdef"},
+		{"short key", "abc123", "…"},
+		{"prefix only", KeyPrefix, KeyPrefix + "…"},
+		{"empty key", "", "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Mask(tt.key); got != tt.expected {
+				t.Errorf("Mask() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
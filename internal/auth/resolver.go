@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// LookupFunc loads the record for an API key from the backing store.
+type LookupFunc[V any] func(ctx context.Context, apiKey string) (V, error)
+
+// resolverShards is the default number of independently locked partitions
+// of a Resolver's cache. Lookups for different keys rarely contend on the
+// same lock, which a single mutex made a bottleneck at high request rates
+// on many cores.
+const resolverShards = 64
+
+// Resolver is a read-through cache mapping API keys to records, so the
+// store is queried once per key per TTL instead of on every request.
+// Entries are indexed by HashKey, never by the raw key. The full hash is
+// used rather than the shorter Fingerprint so that a key can only ever hit
+// its own entry, not that of another key sharing its fingerprint.
+//
+// Expired entries are kept for up to maxStale so that callers can still
+// fall back on them with Stale while the store is unavailable.
+type Resolver[V any] struct {
+	lookup   LookupFunc[V]
+	ttl      time.Duration
+	maxStale time.Duration
+	clock    clock.Clock
+
+	seed   maphash.Seed
+	shards []resolverShard[V]
+}
+
+type resolverShard[V any] struct {
+	mu      sync.RWMutex
+	entries map[string]resolverEntry[V]
+}
+
+type resolverEntry[V any] struct {
+	value     V
+	fetchedAt time.Time
+}
+
+// NewResolver returns a Resolver that caches successful lookups for ttl and
+// keeps them available to Stale for maxStale.
+func NewResolver[V any](lookup LookupFunc[V], ttl, maxStale time.Duration) *Resolver[V] {
+	return newShardedResolver(lookup, ttl, maxStale, resolverShards)
+}
+
+func newShardedResolver[V any](lookup LookupFunc[V], ttl, maxStale time.Duration, shards int) *Resolver[V] {
+	r := &Resolver[V]{
+		lookup:   lookup,
+		ttl:      ttl,
+		maxStale: maxStale,
+		clock:    clock.Real,
+		seed:     maphash.MakeSeed(),
+		shards:   make([]resolverShard[V], shards),
+	}
+	for i := range r.shards {
+		r.shards[i].entries = map[string]resolverEntry[V]{}
+	}
+	return r
+}
+
+// WithClock makes r read time from c. It must be called before r is used.
+func (r *Resolver[V]) WithClock(c clock.Clock) *Resolver[V] {
+	r.clock = c
+	return r
+}
+
+func (r *Resolver[V]) shard(hash string) *resolverShard[V] {
+	return &r.shards[maphash.String(r.seed, hash)%uint64(len(r.shards))]
+}
+
+// Resolve returns the record for apiKey, from cache if fresh. Lookup errors
+// are returned as-is and never cached.
+func (r *Resolver[V]) Resolve(ctx context.Context, apiKey string) (V, error) {
+	hash := HashKey(apiKey)
+	s := r.shard(hash)
+
+	s.mu.RLock()
+	e, ok := s.entries[hash]
+	s.mu.RUnlock()
+	if ok && r.clock.Now().Sub(e.fetchedAt) < r.ttl {
+		return e.value, nil
+	}
+
+	value, err := r.lookup(ctx, apiKey)
+	if err != nil {
+		return value, err
+	}
+
+	s.mu.Lock()
+	s.entries[hash] = resolverEntry[V]{value: value, fetchedAt: r.clock.Now()}
+	s.mu.Unlock()
+	return value, nil
+}
+
+// Stale returns the last resolved record for the key whose HashKey is
+// hash, even past its TTL, as long as it is within maxStale.
+func (r *Resolver[V]) Stale(hash string) (V, bool) {
+	s := r.shard(hash)
+	s.mu.RLock()
+	e, ok := s.entries[hash]
+	s.mu.RUnlock()
+	if ok && r.clock.Now().Sub(e.fetchedAt) <= r.maxStale {
+		return e.value, true
+	}
+
+	if ok {
+		s.mu.Lock()
+		// Only drop the entry if it wasn't refreshed in the meantime.
+		if cur, ok := s.entries[hash]; ok && cur.fetchedAt.Equal(e.fetchedAt) {
+			delete(s.entries, hash)
+		}
+		s.mu.Unlock()
+	}
+	var zero V
+	return zero, false
+}
+
+// Peek returns the cached record for the key whose HashKey is hash and
+// how long ago it was fetched, without looking it up or evicting it.
+func (r *Resolver[V]) Peek(hash string) (value V, age time.Duration, ok bool) {
+	s := r.shard(hash)
+	s.mu.RLock()
+	e, ok := s.entries[hash]
+	s.mu.RUnlock()
+	if !ok {
+		return value, 0, false
+	}
+	return e.value, r.clock.Now().Sub(e.fetchedAt), true
+}
+
+// TTL returns how long a resolved record is served from cache.
+func (r *Resolver[V]) TTL() time.Duration {
+	return r.ttl
+}
+
+// MaxStale returns how long a resolved record stays available to Stale.
+func (r *Resolver[V]) MaxStale() time.Duration {
+	return r.maxStale
+}
+
+// Invalidate drops the cached record for the key whose HashKey is hash.
+// Call it whenever the underlying record changes, e.g. on revocation.
+func (r *Resolver[V]) Invalidate(hash string) {
+	s := r.shard(hash)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, hash)
+}
+
+// Len returns the number of cached entries, including stale ones.
+func (r *Resolver[V]) Len() int {
+	n := 0
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		n += len(s.entries)
+		s.mu.RUnlock()
+	}
+	return n
+}
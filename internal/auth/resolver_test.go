@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+type countingLookup struct {
+	calls int
+	err   error
+}
+
+func (l *countingLookup) lookup(_ context.Context, apiKey string) (string, error) {
+	l.calls++
+	if l.err != nil {
+		return "", l.err
+	}
+	return "user-for-" + apiKey, nil
+}
+
+func newTestResolver(l *countingLookup) (*Resolver[string], *clock.Fake) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(now)
+	return NewResolver(l.lookup, time.Minute, time.Hour).WithClock(c), c
+}
+
+func TestResolver_CachesWithinTTL(t *testing.T) {
+	l := &countingLookup{}
+	r, now := newTestResolver(l)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		v, err := r.Resolve(ctx, "key-1")
+		if err != nil || v != "user-for-key-1" {
+			t.Fatalf("Resolve() = %v, %v", v, err)
+		}
+	}
+	if l.calls != 1 {
+		t.Errorf("lookup called %d times within TTL, want 1", l.calls)
+	}
+
+	now.Advance(time.Minute)
+	if _, err := r.Resolve(ctx, "key-1"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if l.calls != 2 {
+		t.Errorf("lookup called %d times after TTL, want 2", l.calls)
+	}
+}
+
+func TestResolver_Invalidate(t *testing.T) {
+	l := &countingLookup{}
+	r, _ := newTestResolver(l)
+	ctx := context.Background()
+
+	_, _ = r.Resolve(ctx, "key-1")
+	r.Invalidate(HashKey("key-1"))
+	_, _ = r.Resolve(ctx, "key-1")
+
+	if l.calls != 2 {
+		t.Errorf("lookup called %d times, want 2 after Invalidate", l.calls)
+	}
+}
+
+func TestResolver_FingerprintCollision(t *testing.T) {
+	l := &countingLookup{}
+	r, now := newTestResolver(l)
+
+	// Plant an entry for another key whose hash shares key-1's fingerprint.
+	hash := HashKey("key-1")
+	other := Fingerprint("key-1") + strings.Repeat("0", len(hash)-fingerprintLen)
+	r.shard(other).entries[other] = resolverEntry[string]{value: "someone-else", fetchedAt: now.Now()}
+
+	if v, err := r.Resolve(context.Background(), "key-1"); err != nil || v != "user-for-key-1" {
+		t.Errorf("Resolve() = %v, %v, want key-1's own record", v, err)
+	}
+	if l.calls != 1 {
+		t.Errorf("lookup called %d times, want 1", l.calls)
+	}
+}
+
+func TestResolver_DoesNotCacheErrors(t *testing.T) {
+	l := &countingLookup{err: errors.New("not found")}
+	r, _ := newTestResolver(l)
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Resolve(context.Background(), "key-1"); err == nil {
+			t.Fatalf("Resolve() error = nil, want lookup error")
+		}
+	}
+	if l.calls != 2 || r.Len() != 0 {
+		t.Errorf("calls = %d, Len() = %d, want 2, 0", l.calls, r.Len())
+	}
+}
+
+func TestResolver_Stale(t *testing.T) {
+	l := &countingLookup{}
+	r, now := newTestResolver(l)
+
+	if _, ok := r.Stale(HashKey("key-1")); ok {
+		t.Errorf("Stale() before any lookup ok = true")
+	}
+
+	_, _ = r.Resolve(context.Background(), "key-1")
+	now.Advance(30 * time.Minute)
+	if v, ok := r.Stale(HashKey("key-1")); !ok || v != "user-for-key-1" {
+		t.Errorf("Stale() past TTL = %v, %v, want cached value", v, ok)
+	}
+
+	now.Advance(time.Hour)
+	if _, ok := r.Stale(HashKey("key-1")); ok {
+		t.Errorf("Stale() past maxStale ok = true")
+	}
+}
+
+func TestResolver_Peek(t *testing.T) {
+	l := &countingLookup{}
+	r, now := newTestResolver(l)
+
+	if _, _, ok := r.Peek(HashKey("key-1")); ok {
+		t.Errorf("Peek() before any lookup ok = true")
+	}
+	_, _ = r.Resolve(context.Background(), "key-1")
+	now.Advance(2 * time.Hour)
+	v, age, ok := r.Peek(HashKey("key-1"))
+	if !ok || v != "user-for-key-1" || age != 2*time.Hour {
+		t.Errorf("Peek() = %v, %v, %v, want cached value aged 2h", v, age, ok)
+	}
+	if l.calls != 1 || r.Len() != 1 {
+		t.Errorf("calls = %d, Len() = %d after Peek, want 1, 1", l.calls, r.Len())
+	}
+}
+
+func TestResolver_Concurrent(t *testing.T) {
+	lookup := func(_ context.Context, apiKey string) (string, error) { return "user-for-" + apiKey, nil }
+	r := NewResolver(lookup, time.Minute, time.Hour)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := "key-" + strconv.Itoa((g*100+i)%50)
+				if v, err := r.Resolve(context.Background(), key); err != nil || v != "user-for-"+key {
+					t.Errorf("Resolve(%s) = %v, %v", key, v, err)
+				}
+				if i%10 == 0 {
+					r.Invalidate(HashKey(key))
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	if r.Len() > 50 {
+		t.Errorf("Len() = %d, want at most 50", r.Len())
+	}
+}
+
+// BenchmarkResolver_Parallel measures cache hits from many goroutines,
+// comparing a single lock with the sharded default.
+func BenchmarkResolver_Parallel(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+	}
+	lookup := func(_ context.Context, apiKey string) (string, error) { return apiKey, nil }
+
+	for _, shards := range []int{1, resolverShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			r := newShardedResolver(lookup, time.Hour, time.Hour, shards)
+			for _, key := range keys {
+				_, _ = r.Resolve(context.Background(), key)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					key := keys[i%len(keys)]
+					if i%100 == 0 {
+						r.Invalidate(HashKey(key))
+					}
+					if _, err := r.Resolve(context.Background(), key); err != nil {
+						b.Fatal(err)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
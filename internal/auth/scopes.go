@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+const (
+	// ScopeKeysManage allows creating, renaming and revoking API keys.
+	ScopeKeysManage = "keys:manage"
+	// ScopeAccountDelete allows erasing the user and all their data.
+	ScopeAccountDelete = "account:delete"
+)
+
+// KnownScopes lists every scope a key can be granted.
+var KnownScopes = []string{ScopeKeysManage, ScopeAccountDelete}
+
+var (
+	// ErrMissingScope means the caller's key wasn't granted the scope the
+	// operation needs.
+	ErrMissingScope = errors.New("api key lacks the required scope")
+	// ErrNotOwner means the caller may manage keys, but not this one.
+	ErrNotOwner = errors.New("api key belongs to another user")
+	// ErrUnknownScope means a requested scope doesn't exist.
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+// AuthorizeKeyManagement decides whether identity may create, rename or
+// revoke a key owned by ownerID. It needs ScopeKeysManage, and users may
+// only manage their own keys. Operators manage other users' keys through
+// the admin API, which doesn't go through here.
+func AuthorizeKeyManagement(identity Identity, ownerID string) error {
+	if !identity.HasScope(ScopeKeysManage) {
+		return ErrMissingScope
+	}
+	if identity.Type != PrincipalUser || identity.ID != ownerID {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// AuthorizeAccountDeletion decides whether identity may erase the user
+// userID. It needs ScopeAccountDelete, which the key issued with the user
+// holds, and users may only erase themselves.
+func AuthorizeAccountDeletion(identity Identity, userID string) error {
+	if !identity.HasScope(ScopeAccountDelete) {
+		return ErrMissingScope
+	}
+	if identity.Type != PrincipalUser || identity.ID != userID {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// AuthorizeGrant checks that identity may issue a key with scopes: every
+// scope must exist, and a caller can't grant a scope it doesn't hold.
+func AuthorizeGrant(identity Identity, scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(KnownScopes, scope) {
+			return ErrUnknownScope
+		}
+		if !identity.HasScope(scope) {
+			return ErrMissingScope
+		}
+	}
+	return nil
+}
+
+// ParseScopes splits a space-separated scope list, as stored.
+func ParseScopes(s string) []string {
+	return strings.Fields(s)
+}
+
+// FormatScopes is the inverse of ParseScopes.
+func FormatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAuthorizeKeyManagement(t *testing.T) {
+	manager := Identity{ID: "user-1", Type: PrincipalUser, Scopes: []string{ScopeKeysManage}}
+	tests := []struct {
+		name     string
+		identity Identity
+		ownerID  string
+		wantErr  error
+	}{
+		{"own key", manager, "user-1", nil},
+		{"another user's key", manager, "user-2", ErrNotOwner},
+		{"no scopes", Identity{ID: "user-1", Type: PrincipalUser}, "user-1", ErrMissingScope},
+		{"other scopes", Identity{ID: "user-1", Type: PrincipalUser, Scopes: []string{"notes:read"}}, "user-1", ErrMissingScope},
+		{"missing scope checked before ownership", Identity{ID: "user-1", Type: PrincipalUser}, "user-2", ErrMissingScope},
+		{"service with matching ID", Identity{ID: "user-1", Type: PrincipalService, Scopes: []string{ScopeKeysManage}}, "user-1", ErrNotOwner},
+		{"empty owner", manager, "", ErrNotOwner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AuthorizeKeyManagement(tt.identity, tt.ownerID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("AuthorizeKeyManagement() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeAccountDeletion(t *testing.T) {
+	owner := Identity{ID: "user-1", Type: PrincipalUser, Scopes: []string{ScopeAccountDelete}}
+	tests := []struct {
+		name     string
+		identity Identity
+		userID   string
+		wantErr  error
+	}{
+		{"self", owner, "user-1", nil},
+		{"another user", owner, "user-2", ErrNotOwner},
+		{"no scopes", Identity{ID: "user-1", Type: PrincipalUser}, "user-1", ErrMissingScope},
+		{"keys:manage only", Identity{ID: "user-1", Type: PrincipalUser, Scopes: []string{ScopeKeysManage}}, "user-1", ErrMissingScope},
+		{"service with matching ID", Identity{ID: "user-1", Type: PrincipalService, Scopes: []string{ScopeAccountDelete}}, "user-1", ErrNotOwner},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AuthorizeAccountDeletion(tt.identity, tt.userID); !errors.Is(err, tt.wantErr) {
+				t.Errorf("AuthorizeAccountDeletion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeGrant(t *testing.T) {
+	manager := Identity{ID: "user-1", Type: PrincipalUser, Scopes: []string{ScopeKeysManage}}
+	tests := []struct {
+		name     string
+		identity Identity
+		scopes   []string
+		wantErr  error
+	}{
+		{"no scopes", Identity{}, nil, nil},
+		{"held scope", manager, []string{ScopeKeysManage}, nil},
+		{"unheld scope", Identity{}, []string{ScopeKeysManage}, ErrMissingScope},
+		{"unknown scope", manager, []string{"keys:everything"}, ErrUnknownScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AuthorizeGrant(tt.identity, tt.scopes); !errors.Is(err, tt.wantErr) {
+				t.Errorf("AuthorizeGrant() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes := ParseScopes("  keys:manage  notes:read ")
+	if len(scopes) != 2 || scopes[0] != ScopeKeysManage || scopes[1] != "notes:read" {
+		t.Errorf("ParseScopes() = %q", scopes)
+	}
+	if got := FormatScopes(scopes); got != "keys:manage notes:read" {
+		t.Errorf("FormatScopes() = %q", got)
+	}
+	if got := ParseScopes(""); len(got) != 0 {
+		t.Errorf("ParseScopes(\"\") = %q, want none", got)
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kinds of ParseMismatch.
+const (
+	// MismatchCandidateRejects is a header the primary accepted and the
+	// candidate would reject: a client the change would break.
+	MismatchCandidateRejects = "candidate_rejects"
+	// MismatchCandidateAccepts is a header the primary rejected and the
+	// candidate would accept.
+	MismatchCandidateAccepts = "candidate_accepts"
+	// MismatchDifferentKey is a header both accepted but read different
+	// keys from.
+	MismatchDifferentKey = "different_key"
+	// MismatchDifferentError is a header both rejected for different
+	// reasons.
+	MismatchDifferentError = "different_error"
+)
+
+// ShadowParse runs a candidate GetAPIKey configuration alongside the one in
+// use, so that a parsing change such as ParseStrict can be rolled out once
+// it is known which requests it would treat differently. The candidate's
+// result is only compared, never acted on.
+type ShadowParse struct {
+	candidate []Option
+}
+
+// NewShadowParse returns a ShadowParse for GetAPIKey with the candidate
+// options. Options aren't inherited from the primary configuration, so
+// the candidate must list all of its own.
+func NewShadowParse(candidate ...Option) *ShadowParse {
+	return &ShadowParse{candidate: candidate}
+}
+
+// ParseMismatch is how the candidate's result differed from the primary's.
+// Primary and Candidate are the error each returned, or "" for success.
+type ParseMismatch struct {
+	Kind      string
+	Primary   string
+	Candidate string
+}
+
+// Compare parses headers with the candidate configuration and reports
+// whether its result differs from the primary's key and err.
+func (s *ShadowParse) Compare(headers http.Header, key string, err error) (ParseMismatch, bool) {
+	candKey, candErr := GetAPIKey(headers, s.candidate...)
+	m := ParseMismatch{Primary: errString(err), Candidate: errString(candErr)}
+	switch {
+	case err == nil && candErr != nil:
+		m.Kind = MismatchCandidateRejects
+	case err != nil && candErr == nil:
+		m.Kind = MismatchCandidateAccepts
+	case err == nil && key != candKey:
+		m.Kind = MismatchDifferentKey
+	case err != nil && !errors.Is(candErr, err) && !errors.Is(err, candErr):
+		m.Kind = MismatchDifferentError
+	default:
+		return ParseMismatch{}, false
+	}
+	return m, true
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestShadowParse_Compare(t *testing.T) {
+	lenient := []Option{WithMultipleHeaderPolicy(RejectMultipleHeaders)}
+	strict := []Option{WithMultipleHeaderPolicy(RejectMultipleHeaders), WithParseMode(ParseStrict)}
+
+	tests := []struct {
+		name      string
+		primary   []Option
+		candidate []Option
+		header    string
+		wantKind  string
+	}{
+		{"both accept", lenient, strict, "ApiKey test-key", ""},
+		{"both reject alike", lenient, strict, "Bearer test-key", ""},
+		{"missing header", lenient, strict, "", ""},
+		{"candidate rejects", lenient, strict, "ApiKey test-key extra", MismatchCandidateRejects},
+		{"candidate accepts", strict, lenient, "ApiKey test-key extra", MismatchCandidateAccepts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.header != "" {
+				h.Set("Authorization", tt.header)
+			}
+			key, err := GetAPIKey(h, tt.primary...)
+			m, ok := NewShadowParse(tt.candidate...).Compare(h, key, err)
+			if ok != (tt.wantKind != "") || m.Kind != tt.wantKind {
+				t.Errorf("Compare() = %+v, %v, want kind %q", m, ok, tt.wantKind)
+			}
+		})
+	}
+}
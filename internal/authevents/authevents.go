@@ -0,0 +1,401 @@
+// Package authevents is an in-process publish/subscribe bus for
+// authentication events, so other parts of the service can react to logins,
+// failures and key changes without the auth path knowing about them.
+package authevents
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+	"github.com/google/uuid"
+)
+
+// Type names an event kind.
+type Type string
+
+const (
+	TypeLogin          Type = "login"
+	TypeFailure        Type = "failure"
+	TypeKeyCreated     Type = "key_created"
+	TypeKeyRevoked     Type = "key_revoked"
+	TypeHoneytokenUsed Type = "honeytoken_used"
+	TypeStoreFallback  Type = "store_fallback"
+	TypeIdentityErased Type = "identity_erased"
+	TypeAnomaly        Type = "anomaly"
+	TypeBulkRevocation Type = "bulk_revocation"
+	TypeDeprecatedUse  Type = "deprecated_use"
+	TypeShadowMismatch Type = "shadow_mismatch"
+)
+
+// Payload is implemented by every typed event body.
+type Payload interface {
+	EventType() Type
+}
+
+// Login is published when a request authenticates successfully.
+type Login struct {
+	Identity auth.Identity `json:"identity"`
+}
+
+// Failure is published when a request fails authentication.
+type Failure struct {
+	Reason         string `json:"reason"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	RemoteAddr     string `json:"remote_addr"`
+}
+
+// KeyCreated is published when a new API key is issued.
+type KeyCreated struct {
+	UserID         string `json:"user_id"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	// Via is the flow that issued the key, e.g. "signup" or "api".
+	Via string `json:"via"`
+	// CreatedBy is the fingerprint of the credential that requested the
+	// key, if there was one.
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// KeyRevoked is published when an API key is revoked.
+type KeyRevoked struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	Reason         string `json:"reason"`
+}
+
+// HoneytokenUsed is published when a decoy key is presented.
+type HoneytokenUsed struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	RemoteAddr     string `json:"remote_addr"`
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	UserAgent      string `json:"user_agent"`
+}
+
+// StoreFallback records the fail-open/fail-closed decision made when the
+// key store couldn't be reached. Decision is "open" or "closed".
+type StoreFallback struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	RouteClass     string `json:"route_class"`
+	Decision       string `json:"decision"`
+	Error          string `json:"error"`
+}
+
+// IdentityErased is published when a user's personal data is erased. The
+// user is identified only by SubjectHash, a SHA-256 of their ID, so the
+// record can be kept without identifying them.
+type IdentityErased struct {
+	SubjectHash    string `json:"subject_hash"`
+	NotesDeleted   int64  `json:"notes_deleted"`
+	APIKeysDeleted int64  `json:"api_keys_deleted"`
+}
+
+// Anomaly is published when a key's usage deviates sharply from its
+// baseline. Kind is one of the anomaly package's kinds.
+type Anomaly struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	Kind           string `json:"kind"`
+	Detail         string `json:"detail"`
+}
+
+// BulkRevocation summarizes a revocation by filter. The individual keys are
+// published as KeyRevoked. Filter is a human-readable description of the
+// criteria.
+type BulkRevocation struct {
+	Filter  string `json:"filter"`
+	Reason  string `json:"reason"`
+	DryRun  bool   `json:"dry_run"`
+	Matched int    `json:"matched"`
+	Revoked int    `json:"revoked"`
+}
+
+// DeprecatedUse is published when a request authenticates through a legacy
+// behavior that is being retired, such as lenient header parsing.
+type DeprecatedUse struct {
+	Behavior       string `json:"behavior"`
+	KeyFingerprint string `json:"key_fingerprint"`
+}
+
+// ShadowMismatch is published when a candidate implementation running in
+// shadow mode disagrees with the one in use, e.g. strict header parsing.
+// Check names what was compared and Kind how they disagreed; Primary and
+// Candidate are their errors, empty for success.
+type ShadowMismatch struct {
+	Check          string `json:"check"`
+	Kind           string `json:"kind"`
+	Primary        string `json:"primary"`
+	Candidate      string `json:"candidate"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	RemoteAddr     string `json:"remote_addr"`
+}
+
+func (Login) EventType() Type          { return TypeLogin }
+func (Failure) EventType() Type        { return TypeFailure }
+func (KeyCreated) EventType() Type     { return TypeKeyCreated }
+func (KeyRevoked) EventType() Type     { return TypeKeyRevoked }
+func (HoneytokenUsed) EventType() Type { return TypeHoneytokenUsed }
+func (StoreFallback) EventType() Type  { return TypeStoreFallback }
+func (IdentityErased) EventType() Type { return TypeIdentityErased }
+func (Anomaly) EventType() Type        { return TypeAnomaly }
+func (BulkRevocation) EventType() Type { return TypeBulkRevocation }
+func (DeprecatedUse) EventType() Type  { return TypeDeprecatedUse }
+func (ShadowMismatch) EventType() Type { return TypeShadowMismatch }
+
+// Event wraps a payload with delivery metadata. An event that is queued for
+// a subscriber is delivered at least once, so subscribers that need
+// exactly-once effects should dedupe on ID.
+type Event struct {
+	ID      string
+	Time    time.Time
+	Payload Payload
+}
+
+// Handler receives events. Returning an error schedules a redelivery.
+type Handler func(context.Context, Event) error
+
+const (
+	defaultQueueSize     = 256
+	defaultRetryDelay    = 100 * time.Millisecond
+	defaultMaxRetryDelay = 10 * time.Second
+)
+
+// Bus fans published events out to every subscriber. Each subscriber has
+// its own queue and goroutine. Publish never waits for a subscriber: when
+// a subscriber's queue is full, because it is slow or retrying a failure,
+// the event is dropped for that subscriber and counted in Dropped, so a
+// stuck subscriber loses events instead of stalling the requests that
+// publish them. Subscribers that must see every event, such as alerting,
+// use SubscribeUnbounded instead.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []*subscription
+	closed bool
+	wg     sync.WaitGroup
+	abort  chan struct{}
+	clock  clock.Clock
+
+	retryDelay    time.Duration
+	maxRetryDelay time.Duration
+}
+
+type subscription struct {
+	name    string
+	handler Handler
+	queue   chan Event
+	dropped atomic.Uint64
+	// backlog replaces queue for subscriptions made with
+	// SubscribeUnbounded.
+	backlog *backlog
+}
+
+// backlog is an unbounded FIFO of events.
+type backlog struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+	ready  chan struct{}
+}
+
+func newBacklog() *backlog {
+	return &backlog{ready: make(chan struct{}, 1)}
+}
+
+func (q *backlog) push(ev Event) {
+	q.mu.Lock()
+	q.events = append(q.events, ev)
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *backlog) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *backlog) wake() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next event. It reports false once the backlog is
+// closed and empty.
+func (q *backlog) pop() (Event, bool) {
+	for {
+		q.mu.Lock()
+		if len(q.events) > 0 {
+			ev := q.events[0]
+			q.events[0] = Event{}
+			q.events = q.events[1:]
+			q.mu.Unlock()
+			return ev, true
+		}
+		closed := q.closed
+		q.mu.Unlock()
+		if closed {
+			return Event{}, false
+		}
+		<-q.ready
+	}
+}
+
+// NewBus returns an empty, running bus.
+func NewBus() *Bus {
+	return &Bus{
+		abort:         make(chan struct{}),
+		clock:         clock.Real,
+		retryDelay:    defaultRetryDelay,
+		maxRetryDelay: defaultMaxRetryDelay,
+	}
+}
+
+// WithClock makes b timestamp events with c. It must be called before b is
+// used.
+func (b *Bus) WithClock(c clock.Clock) *Bus {
+	b.clock = c
+	return b
+}
+
+// Subscribe registers handler under name. Events published before the call
+// are not replayed.
+func (b *Bus) Subscribe(name string, handler Handler) {
+	b.subscribe(&subscription{
+		name:    name,
+		handler: handler,
+		queue:   make(chan Event, defaultQueueSize),
+	})
+}
+
+// SubscribeUnbounded is Subscribe for a handler that must see every event.
+// Its events are never dropped: while it falls behind they are held in
+// memory without limit, so handler must be quick and must not wait on
+// anything that can stall, such as a network call.
+func (b *Bus) SubscribeUnbounded(name string, handler Handler) {
+	b.subscribe(&subscription{
+		name:    name,
+		handler: handler,
+		backlog: newBacklog(),
+	})
+}
+
+func (b *Bus) subscribe(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.subs = append(b.subs, sub)
+	b.wg.Add(1)
+	go b.run(sub)
+}
+
+// Publish delivers payload to every subscriber. It is a no-op on a nil or
+// closed bus, so callers don't need to guard optional wiring.
+func (b *Bus) Publish(payload Payload) {
+	if b == nil {
+		return
+	}
+	ev := Event{
+		ID:      uuid.New().String(),
+		Time:    b.clock.Now().UTC(),
+		Payload: payload,
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs {
+		if sub.backlog != nil {
+			sub.backlog.push(ev)
+			continue
+		}
+		select {
+		case sub.queue <- ev:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns, per subscriber name, how many events were dropped
+// because the subscriber's queue was full.
+func (b *Bus) Dropped() map[string]uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	dropped := make(map[string]uint64, len(b.subs))
+	for _, sub := range b.subs {
+		dropped[sub.name] += sub.dropped.Load()
+	}
+	return dropped
+}
+
+// Close stops accepting events and waits for queued ones to be delivered.
+// If ctx ends first, pending retries are abandoned and ctx.Err is returned.
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, sub := range b.subs {
+			if sub.backlog != nil {
+				sub.backlog.close()
+			} else {
+				close(sub.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		close(b.abort)
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (b *Bus) run(sub *subscription) {
+	defer b.wg.Done()
+	if sub.backlog != nil {
+		for ev, ok := sub.backlog.pop(); ok; ev, ok = sub.backlog.pop() {
+			b.deliver(sub, ev)
+		}
+		return
+	}
+	for ev := range sub.queue {
+		b.deliver(sub, ev)
+	}
+}
+
+func (b *Bus) deliver(sub *subscription, ev Event) {
+	delay := b.retryDelay
+	for {
+		err := sub.handler(context.Background(), ev)
+		if err == nil {
+			return
+		}
+		log.Printf("auth event %s (%s) to %s failed, retrying in %s: %v", ev.ID, ev.Payload.EventType(), sub.name, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-b.abort:
+			log.Printf("auth event %s (%s) to %s abandoned at shutdown", ev.ID, ev.Payload.EventType(), sub.name)
+			return
+		}
+		delay = min(delay*2, b.maxRetryDelay)
+	}
+}
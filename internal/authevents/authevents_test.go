@@ -0,0 +1,195 @@
+package authevents
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func TestBus_DeliversToAllSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	var mu sync.Mutex
+	got := map[string][]Type{}
+	for _, name := range []string{"a", "b"} {
+		bus.Subscribe(name, func(_ context.Context, ev Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got[name] = append(got[name], ev.Payload.EventType())
+			return nil
+		})
+	}
+
+	bus.Publish(Login{Identity: auth.Identity{ID: "user-1"}})
+	bus.Publish(KeyRevoked{KeyFingerprint: "abc"})
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		if len(got[name]) != 2 || got[name][0] != TypeLogin || got[name][1] != TypeKeyRevoked {
+			t.Errorf("subscriber %s got %v, want [login key_revoked]", name, got[name])
+		}
+	}
+}
+
+func TestBus_RetriesFailedDelivery(t *testing.T) {
+	bus := NewBus()
+	bus.retryDelay = time.Millisecond
+
+	var ids []string
+	attempts := 0
+	bus.Subscribe("flaky", func(_ context.Context, ev Event) error {
+		attempts++
+		ids = append(ids, ev.ID)
+		if attempts < 3 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+
+	bus.Publish(Failure{Reason: "bad key"})
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if attempts != 3 {
+		t.Errorf("handler called %d times, want 3", attempts)
+	}
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Errorf("redelivery changed event ID from %s to %s", ids[0], id)
+		}
+	}
+}
+
+func TestBus_CloseAbandonsRetriesAfterDeadline(t *testing.T) {
+	bus := NewBus()
+	bus.retryDelay = time.Millisecond
+	bus.maxRetryDelay = time.Millisecond
+	bus.Subscribe("broken", func(context.Context, Event) error {
+		return errors.New("always fails")
+	})
+
+	bus.Publish(Login{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestBus_PublishDropsWhenQueueFull(t *testing.T) {
+	bus := NewBus()
+	started, release := make(chan struct{}), make(chan struct{})
+	delivered := 0
+	bus.Subscribe("stuck", func(context.Context, Event) error {
+		if delivered == 0 {
+			close(started)
+			<-release
+		}
+		delivered++
+		return nil
+	})
+
+	bus.Publish(Login{})
+	<-started
+	// The handler holds the first event, so the queue fills after
+	// defaultQueueSize more and Publish must not wait for it.
+	for i := 0; i < defaultQueueSize+5; i++ {
+		bus.Publish(Login{})
+	}
+	if got := bus.Dropped()["stuck"]; got != 5 {
+		t.Errorf("Dropped() = %d, want 5", got)
+	}
+
+	close(release)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if delivered != defaultQueueSize+1 {
+		t.Errorf("delivered %d events, want %d", delivered, defaultQueueSize+1)
+	}
+}
+
+func TestBus_UnboundedSubscriberNeverDrops(t *testing.T) {
+	bus := NewBus()
+	started, release := make(chan struct{}), make(chan struct{})
+	var got []string
+	bus.SubscribeUnbounded("alerting", func(_ context.Context, ev Event) error {
+		if len(got) == 0 {
+			close(started)
+			<-release
+		}
+		got = append(got, ev.Payload.(Failure).Reason)
+		return nil
+	})
+
+	bus.Publish(Failure{Reason: "0"})
+	<-started
+	const n = 4 * defaultQueueSize
+	for i := 1; i <= n; i++ {
+		bus.Publish(Failure{Reason: strconv.Itoa(i)})
+	}
+	if got := bus.Dropped()["alerting"]; got != 0 {
+		t.Errorf("Dropped() = %d, want 0", got)
+	}
+
+	close(release)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(got) != n+1 {
+		t.Fatalf("delivered %d events, want %d", len(got), n+1)
+	}
+	for i, reason := range got {
+		if reason != strconv.Itoa(i) {
+			t.Fatalf("event %d delivered as %s, want in publish order", i, reason)
+		}
+	}
+}
+
+func TestBus_PublishAfterCloseIsNoop(t *testing.T) {
+	bus := NewBus()
+	called := false
+	bus.Subscribe("s", func(context.Context, Event) error {
+		called = true
+		return nil
+	})
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	bus.Publish(Login{})
+	var nilBus *Bus
+	nilBus.Publish(Login{})
+
+	if called {
+		t.Errorf("handler called after Close")
+	}
+}
+
+func TestBus_TimestampsWithClock(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bus := NewBus().WithClock(clock.NewFake(at))
+
+	var got time.Time
+	bus.Subscribe("a", func(_ context.Context, ev Event) error {
+		got = ev.Time
+		return nil
+	})
+	bus.Publish(Login{Identity: auth.Identity{ID: "user-1"}})
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !got.Equal(at) {
+		t.Errorf("event time = %v, want %v", got, at)
+	}
+}
@@ -0,0 +1,118 @@
+package authevents
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Severity ranks events for operators watching a live stream.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityCritical:
+		return "critical"
+	}
+	return "info"
+}
+
+// ParseSeverity is the inverse of Severity.String.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// SeverityOf classifies p. Decoy keys and fail-open decisions are critical,
+// since either means someone may be inside who shouldn't be.
+func SeverityOf(p Payload) Severity {
+	switch p := p.(type) {
+	case HoneytokenUsed:
+		return SeverityCritical
+	case StoreFallback:
+		if p.Decision == "open" {
+			return SeverityCritical
+		}
+		return SeverityWarning
+	case Failure, KeyRevoked, Anomaly, BulkRevocation:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// Stream relays bus events to any number of live listeners, such as
+// operators tailing events during an incident. Unlike Bus subscribers,
+// listeners are best effort: one that can't keep up misses events rather
+// than holding up the bus.
+type Stream struct {
+	mu        sync.Mutex
+	listeners map[*listener]struct{}
+}
+
+type listener struct {
+	ch      chan Event
+	dropped atomic.Int64
+}
+
+// NewStream returns a stream with no listeners.
+func NewStream() *Stream {
+	return &Stream{listeners: map[*listener]struct{}{}}
+}
+
+// Handle is a Handler; subscribe it to a Bus to feed the stream.
+func (s *Stream) Handle(_ context.Context, ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		select {
+		case l.ch <- ev:
+		default:
+			l.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Listen registers a listener buffering up to buffer events. The returned
+// dropped func reports how many events were lost to a full buffer so far,
+// and cancel unregisters the listener and closes the channel.
+func (s *Stream) Listen(buffer int) (events <-chan Event, dropped func() int64, cancel func()) {
+	l := &listener{ch: make(chan Event, buffer)}
+	s.mu.Lock()
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			close(l.ch)
+		})
+	}
+	return l.ch, l.dropped.Load, cancel
+}
+
+// Len returns the number of registered listeners.
+func (s *Stream) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.listeners)
+}
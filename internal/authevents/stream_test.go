@@ -0,0 +1,80 @@
+package authevents
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStream_FansOutToListeners(t *testing.T) {
+	s := NewStream()
+	a, _, cancelA := s.Listen(4)
+	b, _, cancelB := s.Listen(4)
+	defer cancelB()
+
+	_ = s.Handle(context.Background(), Event{ID: "1", Payload: KeyRevoked{}})
+	for name, ch := range map[string]<-chan Event{"a": a, "b": b} {
+		if ev := <-ch; ev.ID != "1" {
+			t.Errorf("listener %s got %q, want 1", name, ev.ID)
+		}
+	}
+
+	cancelA()
+	cancelA()
+	if _, ok := <-a; ok {
+		t.Errorf("channel open after cancel")
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", s.Len())
+	}
+}
+
+func TestStream_DropsForSlowListener(t *testing.T) {
+	s := NewStream()
+	ch, dropped, cancel := s.Listen(1)
+	defer cancel()
+
+	for _, id := range []string{"1", "2", "3"} {
+		if err := s.Handle(context.Background(), Event{ID: id, Payload: Login{}}); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	if ev := <-ch; ev.ID != "1" {
+		t.Errorf("got %q, want the first event", ev.ID)
+	}
+	if n := dropped(); n != 2 {
+		t.Errorf("dropped() = %d, want 2", n)
+	}
+}
+
+func TestSeverityOf(t *testing.T) {
+	tests := []struct {
+		payload Payload
+		want    Severity
+	}{
+		{Login{}, SeverityInfo},
+		{KeyCreated{}, SeverityInfo},
+		{Failure{}, SeverityWarning},
+		{KeyRevoked{}, SeverityWarning},
+		{Anomaly{}, SeverityWarning},
+		{BulkRevocation{}, SeverityWarning},
+		{StoreFallback{Decision: "closed"}, SeverityWarning},
+		{StoreFallback{Decision: "open"}, SeverityCritical},
+		{HoneytokenUsed{}, SeverityCritical},
+	}
+	for _, tt := range tests {
+		if got := SeverityOf(tt.payload); got != tt.want {
+			t.Errorf("SeverityOf(%T) = %v, want %v", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		if got, err := ParseSeverity(s.String()); err != nil || got != s {
+			t.Errorf("ParseSeverity(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseSeverity("loud"); err == nil {
+		t.Errorf("ParseSeverity(loud) error = nil")
+	}
+}
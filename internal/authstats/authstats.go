@@ -0,0 +1,184 @@
+// Package authstats aggregates auth events into the figures an operator
+// dashboard needs. Figures cover the life of the process.
+package authstats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// issuanceBuckets is how many hourly buckets of key creations are kept.
+const issuanceBuckets = 24
+
+// Collector counts auth events. Its Handle method subscribes it to an
+// authevents.Bus. It is safe for concurrent use.
+type Collector struct {
+	clock clock.Clock
+	since time.Time
+
+	mu       sync.Mutex
+	failures map[string]int64
+	requests map[string]int64
+	// deprecated counts requests per key for each legacy behavior.
+	deprecated map[string]map[string]int64
+	// shadow counts shadow mismatches per check and kind.
+	shadow  map[string]map[string]int64
+	created [issuanceBuckets]hourBucket
+}
+
+type hourBucket struct {
+	hour  time.Time
+	count int64
+}
+
+// NewCollector returns an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{
+		clock:      clock.Real,
+		since:      clock.Real.Now(),
+		failures:   map[string]int64{},
+		requests:   map[string]int64{},
+		deprecated: map[string]map[string]int64{},
+		shadow:     map[string]map[string]int64{},
+	}
+}
+
+// WithClock makes c read time from clk, starting now. It must be called
+// before c is used.
+func (c *Collector) WithClock(clk clock.Clock) *Collector {
+	c.clock = clk
+	c.since = clk.Now()
+	return c
+}
+
+// Handle records ev. It never fails.
+func (c *Collector) Handle(_ context.Context, ev authevents.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch p := ev.Payload.(type) {
+	case authevents.Failure:
+		c.failures[p.Reason]++
+	case authevents.Login:
+		c.requests[p.Identity.CredentialID]++
+	case authevents.DeprecatedUse:
+		if c.deprecated[p.Behavior] == nil {
+			c.deprecated[p.Behavior] = map[string]int64{}
+		}
+		c.deprecated[p.Behavior][p.KeyFingerprint]++
+	case authevents.ShadowMismatch:
+		if c.shadow[p.Check] == nil {
+			c.shadow[p.Check] = map[string]int64{}
+		}
+		c.shadow[p.Check][p.Kind]++
+	case authevents.KeyCreated:
+		hour := ev.Time.Truncate(time.Hour)
+		b := &c.created[hour.Unix()/3600%issuanceBuckets]
+		if !b.hour.Equal(hour) {
+			*b = hourBucket{hour: hour}
+		}
+		b.count++
+	}
+	return nil
+}
+
+// KeyVolume is the number of authenticated requests made with a key.
+type KeyVolume struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	Requests       int64  `json:"requests"`
+}
+
+// Snapshot is a point-in-time copy of the collected figures.
+type Snapshot struct {
+	Since            time.Time        `json:"since"`
+	FailuresByReason map[string]int64 `json:"failures_by_reason"`
+	TopKeys          []KeyVolume      `json:"top_keys"`
+	KeysCreated      KeysCreated      `json:"keys_created"`
+	// DeprecatedUse is keyed by legacy behavior.
+	DeprecatedUse map[string]DeprecatedUsage `json:"deprecated_use"`
+	// ShadowMismatches counts disagreements of candidates running in
+	// shadow mode, by check and then kind.
+	ShadowMismatches map[string]map[string]int64 `json:"shadow_mismatches"`
+}
+
+// DeprecatedUsage is how much a legacy behavior is still used, and by the
+// topN keys using it most, so their owners can be asked to migrate.
+type DeprecatedUsage struct {
+	Requests int64       `json:"requests"`
+	Keys     int         `json:"keys"`
+	TopKeys  []KeyVolume `json:"top_keys"`
+}
+
+// KeysCreated counts key issuance in the current clock hour and in the 24
+// clock hours up to and including it.
+type KeysCreated struct {
+	CurrentHour int64 `json:"current_hour"`
+	Last24h     int64 `json:"last_24h"`
+}
+
+// Snapshot returns the current figures with the topN keys by volume.
+func (c *Collector) Snapshot(topN int) Snapshot {
+	now := c.clock.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Snapshot{
+		Since:            c.since,
+		FailuresByReason: make(map[string]int64, len(c.failures)),
+	}
+	for reason, n := range c.failures {
+		s.FailuresByReason[reason] = n
+	}
+	s.TopKeys = topKeys(c.requests, topN)
+	s.DeprecatedUse = make(map[string]DeprecatedUsage, len(c.deprecated))
+	for behavior, keys := range c.deprecated {
+		u := DeprecatedUsage{Keys: len(keys), TopKeys: topKeys(keys, topN)}
+		for _, n := range keys {
+			u.Requests += n
+		}
+		s.DeprecatedUse[behavior] = u
+	}
+	s.ShadowMismatches = make(map[string]map[string]int64, len(c.shadow))
+	for check, kinds := range c.shadow {
+		s.ShadowMismatches[check] = make(map[string]int64, len(kinds))
+		for kind, n := range kinds {
+			s.ShadowMismatches[check][kind] = n
+		}
+	}
+
+	current := now.Truncate(time.Hour)
+	for _, b := range c.created {
+		age := current.Sub(b.hour)
+		if b.hour.IsZero() || age < 0 || age >= issuanceBuckets*time.Hour {
+			continue
+		}
+		s.KeysCreated.Last24h += b.count
+		if age == 0 {
+			s.KeysCreated.CurrentHour += b.count
+		}
+	}
+	return s
+}
+
+// topKeys returns the n keys with the most requests in counts, busiest
+// first.
+func topKeys(counts map[string]int64, n int) []KeyVolume {
+	keys := make([]KeyVolume, 0, len(counts))
+	for fp, requests := range counts {
+		keys = append(keys, KeyVolume{KeyFingerprint: fp, Requests: requests})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Requests != keys[j].Requests {
+			return keys[i].Requests > keys[j].Requests
+		}
+		return keys[i].KeyFingerprint < keys[j].KeyFingerprint
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
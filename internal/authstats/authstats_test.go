@@ -0,0 +1,73 @@
+package authstats
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func TestCollector(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	c := clock.NewFake(start)
+	col := NewCollector().WithClock(c)
+	ctx := context.Background()
+
+	publish := func(at time.Time, p authevents.Payload) {
+		if err := col.Handle(ctx, authevents.Event{Time: at, Payload: p}); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+	}
+	login := func(fp string) authevents.Login {
+		return authevents.Login{Identity: auth.Identity{CredentialID: fp}}
+	}
+
+	publish(start, authevents.Failure{Reason: "unknown api key"})
+	publish(start, authevents.Failure{Reason: "unknown api key"})
+	publish(start, authevents.Failure{Reason: "revoked api key"})
+	for i := 0; i < 3; i++ {
+		publish(start, login("fp-a"))
+	}
+	publish(start, login("fp-b"))
+	for i := 0; i < 2; i++ {
+		publish(start, login("fp-c"))
+	}
+	publish(start, authevents.DeprecatedUse{Behavior: "lenient_parsing", KeyFingerprint: "fp-b"})
+	publish(start, authevents.DeprecatedUse{Behavior: "lenient_parsing", KeyFingerprint: "fp-b"})
+	publish(start, authevents.DeprecatedUse{Behavior: "lenient_parsing", KeyFingerprint: "fp-c"})
+	publish(start, authevents.ShadowMismatch{Check: "parse", Kind: "candidate_rejects"})
+	publish(start, authevents.ShadowMismatch{Check: "parse", Kind: "candidate_rejects"})
+	publish(start, authevents.ShadowMismatch{Check: "parse", Kind: "different_error"})
+	publish(start.Add(-25*time.Hour), authevents.KeyCreated{})
+	publish(start.Add(-2*time.Hour), authevents.KeyCreated{})
+	publish(start, authevents.KeyCreated{})
+	publish(start, authevents.KeyCreated{})
+
+	got := col.Snapshot(2)
+	want := Snapshot{
+		Since:            start,
+		FailuresByReason: map[string]int64{"unknown api key": 2, "revoked api key": 1},
+		TopKeys:          []KeyVolume{{KeyFingerprint: "fp-a", Requests: 3}, {KeyFingerprint: "fp-c", Requests: 2}},
+		KeysCreated:      KeysCreated{CurrentHour: 2, Last24h: 3},
+		DeprecatedUse: map[string]DeprecatedUsage{
+			"lenient_parsing": {
+				Requests: 3,
+				Keys:     2,
+				TopKeys:  []KeyVolume{{KeyFingerprint: "fp-b", Requests: 2}, {KeyFingerprint: "fp-c", Requests: 1}},
+			},
+		},
+		ShadowMismatches: map[string]map[string]int64{"parse": {"candidate_rejects": 2, "different_error": 1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %+v, want %+v", got, want)
+	}
+
+	c.Advance(time.Hour)
+	if got := col.Snapshot(2).KeysCreated; got != (KeysCreated{CurrentHour: 0, Last24h: 3}) {
+		t.Errorf("KeysCreated an hour later = %+v", got)
+	}
+}
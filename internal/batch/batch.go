@@ -0,0 +1,170 @@
+// Package batch moves writes off the request path: items are queued and
+// written in batches by a background goroutine, so callers don't wait on a
+// database round trip per item.
+package batch
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Overflow decides what Add does when the queue is full.
+type Overflow int
+
+const (
+	// Block makes Add wait for room, pushing back on the caller.
+	Block Overflow = iota
+	// Drop makes Add discard the item. Use it for writes that are only
+	// advisory, so a slow store never adds latency to the caller.
+	Drop
+)
+
+// Config tunes a Writer. Zero fields take the defaults.
+type Config struct {
+	// QueueSize bounds the items waiting to be written. Default 1024.
+	QueueSize int
+	// MaxBatch is the most items passed to one flush. Default 100.
+	MaxBatch int
+	// Interval is the longest an item waits for its batch to fill.
+	// Default 1s.
+	Interval time.Duration
+	Overflow Overflow
+}
+
+const (
+	defaultQueueSize = 1024
+	defaultMaxBatch  = 100
+	defaultInterval  = time.Second
+)
+
+// FlushFunc writes a batch. A failed batch is logged and discarded rather
+// than retried, so the queue can't back up behind a broken store.
+type FlushFunc[T any] func(context.Context, []T) error
+
+// Writer queues items and flushes them in batches.
+type Writer[T any] struct {
+	name     string
+	flush    FlushFunc[T]
+	maxBatch int
+	interval time.Duration
+	overflow Overflow
+
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan T
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// NewWriter starts a writer passing batches to flush. name identifies it
+// in logs.
+func NewWriter[T any](name string, cfg Config, flush FlushFunc[T]) *Writer[T] {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = defaultMaxBatch
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	w := &Writer[T]{
+		name:     name,
+		flush:    flush,
+		maxBatch: cfg.MaxBatch,
+		interval: cfg.Interval,
+		overflow: cfg.Overflow,
+		queue:    make(chan T, cfg.QueueSize),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add queues item. It reports false if the item was dropped: because the
+// queue was full under Drop, ctx ended while blocked under Block, or w is
+// closed.
+func (w *Writer[T]) Add(ctx context.Context, item T) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return false
+	}
+
+	if w.overflow == Drop {
+		select {
+		case w.queue <- item:
+			return true
+		default:
+			w.dropped.Add(1)
+			return false
+		}
+	}
+	select {
+	case w.queue <- item:
+		return true
+	case <-ctx.Done():
+		w.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns how many items Add has discarded.
+func (w *Writer[T]) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Close stops accepting items and waits for queued ones to be flushed, or
+// for ctx to end.
+func (w *Writer[T]) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer[T]) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]T, 0, w.maxBatch)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.flush(context.Background(), batch); err != nil {
+			log.Printf("batch %s: couldn't write %d items: %v", w.name, len(batch), err)
+		}
+		batch = make([]T, 0, w.maxBatch)
+	}
+
+	for {
+		select {
+		case item, ok := <-w.queue:
+			if !ok {
+				write()
+				return
+			}
+			batch = append(batch, item)
+			if len(batch) >= w.maxBatch {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		}
+	}
+}
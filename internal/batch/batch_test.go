@@ -0,0 +1,106 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (r *recorder) flush(_ context.Context, items []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, items)
+	return nil
+}
+
+func TestWriter_BatchesAndFlushesOnClose(t *testing.T) {
+	r := &recorder{}
+	w := NewWriter("test", Config{MaxBatch: 2, Interval: time.Hour}, r.flush)
+
+	for i := 1; i <= 5; i++ {
+		if !w.Add(context.Background(), i) {
+			t.Fatalf("Add(%d) = false", i)
+		}
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := [][]int{{1, 2}, {3, 4}, {5}}
+	if len(r.batches) != len(want) {
+		t.Fatalf("batches = %v, want %v", r.batches, want)
+	}
+	for i := range want {
+		if len(r.batches[i]) != len(want[i]) || r.batches[i][0] != want[i][0] {
+			t.Errorf("batch %d = %v, want %v", i, r.batches[i], want[i])
+		}
+	}
+
+	if w.Add(context.Background(), 6) {
+		t.Errorf("Add() after Close = true")
+	}
+}
+
+func TestWriter_FlushesOnInterval(t *testing.T) {
+	flushed := make(chan []int, 1)
+	w := NewWriter("test", Config{Interval: 10 * time.Millisecond}, func(_ context.Context, items []int) error {
+		flushed <- items
+		return nil
+	})
+	defer w.Close(context.Background())
+
+	w.Add(context.Background(), 1)
+	select {
+	case items := <-flushed:
+		if len(items) != 1 || items[0] != 1 {
+			t.Errorf("flushed %v, want [1]", items)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("partial batch not flushed on interval")
+	}
+}
+
+func TestWriter_Overflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		overflow Overflow
+	}{
+		{"drop", Drop},
+		{"block", Block},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			w := NewWriter("test", Config{QueueSize: 1, MaxBatch: 1, Overflow: tt.overflow}, func(context.Context, []int) error {
+				<-release
+				return nil
+			})
+
+			// The first item is taken by the stuck flush, the second fills
+			// the queue.
+			w.Add(context.Background(), 1)
+			time.Sleep(10 * time.Millisecond)
+			w.Add(context.Background(), 2)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if w.Add(ctx, 3) {
+				t.Errorf("Add() to full queue = true")
+			}
+			if w.Dropped() != 1 {
+				t.Errorf("Dropped() = %d, want 1", w.Dropped())
+			}
+
+			close(release)
+			if err := w.Close(context.Background()); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+// Package breaker implements a circuit breaker for calls to backing stores,
+// so an outage turns into fast, explicit failures instead of every request
+// waiting on a dead dependency.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// ErrOpen is returned without calling the wrapped function while the
+// breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the breaker's current mode.
+type State int
+
+const (
+	// Closed passes calls through and counts consecutive failures.
+	Closed State = iota
+	// Open rejects calls until the cooldown has passed.
+	Open
+	// HalfOpen lets a single probe call through to test recovery.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker opens after threshold consecutive failures and stays open for
+// cooldown before allowing a probe. IsFailure decides which errors count;
+// errors it rejects (e.g. "not found") are treated as successful calls.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	isFailure func(error) bool
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// New returns a closed breaker. A nil isFailure counts every error.
+func New(threshold int, cooldown time.Duration, isFailure func(error) bool) *Breaker {
+	if isFailure == nil {
+		isFailure = func(error) bool { return true }
+	}
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		isFailure: isFailure,
+		clock:     clock.Real,
+	}
+}
+
+// WithClock makes b read time from c. It must be called before b is used.
+func (b *Breaker) WithClock(c clock.Clock) *Breaker {
+	b.clock = c
+	return b
+}
+
+// Do calls fn if the breaker allows it and records the outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := fn()
+	b.record(err != nil && b.isFailure(err))
+	return err
+}
+
+// State reports the current state, moving an expired Open to HalfOpen.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.clock.Now().Sub(b.openedAt) >= b.cooldown {
+		return HalfOpen
+	}
+	return b.state
+}
+
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Open:
+		if b.clock.Now().Sub(b.openedAt) < b.cooldown {
+			return ErrOpen
+		}
+		b.state = HalfOpen
+		b.probing = true
+		return nil
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+		if failed {
+			b.trip()
+			return
+		}
+		b.state = Closed
+		b.failures = 0
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.trip()
+	}
+}
+
+func (b *Breaker) trip() {
+	b.state = Open
+	b.openedAt = b.clock.Now()
+	b.failures = 0
+}
@@ -0,0 +1,92 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+var (
+	errDown     = errors.New("database is down")
+	errNotFound = errors.New("not found")
+)
+
+func newTestBreaker() (*Breaker, *clock.Fake) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(now)
+	return New(3, time.Minute, func(err error) bool { return err != errNotFound }).WithClock(c), c
+}
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker()
+
+	for i := 0; i < 3; i++ {
+		if err := b.Do(func() error { return errDown }); err != errDown {
+			t.Fatalf("call %d error = %v, want %v", i, err, errDown)
+		}
+	}
+	if b.State() != Open {
+		t.Fatalf("State() = %v, want open", b.State())
+	}
+
+	called := false
+	if err := b.Do(func() error { called = true; return nil }); err != ErrOpen {
+		t.Errorf("Do() while open error = %v, want %v", err, ErrOpen)
+	}
+	if called {
+		t.Errorf("fn called while breaker open")
+	}
+}
+
+func TestBreaker_IgnoresNonFailures(t *testing.T) {
+	b, _ := newTestBreaker()
+
+	for i := 0; i < 10; i++ {
+		_ = b.Do(func() error { return errNotFound })
+	}
+	_ = b.Do(func() error { return errDown })
+	_ = b.Do(func() error { return errDown })
+	_ = b.Do(func() error { return nil })
+	_ = b.Do(func() error { return errDown })
+
+	if b.State() != Closed {
+		t.Errorf("State() = %v, want closed", b.State())
+	}
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		probeErr error
+		expected State
+	}{
+		{"successful probe closes", nil, Closed},
+		{"failed probe reopens", errDown, Open},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, now := newTestBreaker()
+			for i := 0; i < 3; i++ {
+				_ = b.Do(func() error { return errDown })
+			}
+
+			now.Advance(time.Minute)
+			if b.State() != HalfOpen {
+				t.Fatalf("State() after cooldown = %v, want half-open", b.State())
+			}
+
+			_ = b.Do(func() error {
+				if err := b.Do(func() error { return nil }); err != ErrOpen {
+					t.Errorf("concurrent probe error = %v, want %v", err, ErrOpen)
+				}
+				return tt.probeErr
+			})
+			if b.State() != tt.expected {
+				t.Errorf("State() after probe = %v, want %v", b.State(), tt.expected)
+			}
+		})
+	}
+}
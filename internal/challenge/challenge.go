@@ -0,0 +1,102 @@
+// Package challenge slows down automated credential stuffing. Clients that
+// fail authentication repeatedly must solve a challenge before their next
+// attempt is even looked at.
+package challenge
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// Headers carrying a challenge to the client and its solution back.
+const (
+	Header         = "Auth-Challenge"
+	ResponseHeader = "Auth-Challenge-Response"
+)
+
+var (
+	ErrMissingSolution = errors.New("challenge solution required")
+	ErrInvalidSolution = errors.New("invalid challenge solution")
+	ErrExpired         = errors.New("challenge expired")
+	ErrReplayed        = errors.New("challenge solution already used")
+)
+
+// Provider issues challenges and checks solutions. Client identifies the
+// caller, e.g. by IP address; a solution is only valid for the client its
+// challenge was issued to. Implementations could wrap a CAPTCHA service;
+// ProofOfWork needs no third party.
+type Provider interface {
+	// Challenge returns the Header value describing a new challenge.
+	Challenge(client string) (string, error)
+	// Verify checks a ResponseHeader value.
+	Verify(client, solution string) error
+}
+
+// Tracker counts authentication failures per client in a fixed window and
+// reports which clients must solve a challenge.
+type Tracker struct {
+	threshold int
+	window    time.Duration
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	failures map[string]*failureCount
+}
+
+type failureCount struct {
+	count int
+	start time.Time
+}
+
+// NewTracker returns a Tracker that requires a challenge once a client has
+// failed threshold times within window.
+func NewTracker(threshold int, window time.Duration) *Tracker {
+	return &Tracker{
+		threshold: threshold,
+		window:    window,
+		clock:     clock.Real,
+		failures:  map[string]*failureCount{},
+	}
+}
+
+// WithClock makes t read time from c. It must be called before t is used.
+func (t *Tracker) WithClock(c clock.Clock) *Tracker {
+	t.clock = c
+	return t
+}
+
+// Fail records an authentication failure by client.
+func (t *Tracker) Fail(client string) {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweep(now)
+	f, ok := t.failures[client]
+	if !ok {
+		f = &failureCount{start: now}
+		t.failures[client] = f
+	}
+	f.count++
+}
+
+// Required reports whether client must solve a challenge.
+func (t *Tracker) Required(client string) bool {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f, ok := t.failures[client]
+	return ok && now.Sub(f.start) < t.window && f.count >= t.threshold
+}
+
+// sweep drops windows that have ended. It is cheap enough to run on every
+// failure because it only ever touches clients that failed recently.
+func (t *Tracker) sweep(now time.Time) {
+	for client, f := range t.failures {
+		if now.Sub(f.start) >= t.window {
+			delete(t.failures, client)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package challenge
+
+import (
+	"crypto/sha256"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func TestTracker(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tr := NewTracker(3, time.Minute).WithClock(c)
+
+	for i := 0; i < 2; i++ {
+		tr.Fail("1.2.3.4")
+	}
+	if tr.Required("1.2.3.4") {
+		t.Fatalf("Required() after 2 failures = true, want false")
+	}
+	tr.Fail("1.2.3.4")
+	if !tr.Required("1.2.3.4") {
+		t.Fatalf("Required() after 3 failures = false, want true")
+	}
+	if tr.Required("5.6.7.8") {
+		t.Errorf("Required() for other client = true, want false")
+	}
+
+	c.Advance(time.Minute)
+	if tr.Required("1.2.3.4") {
+		t.Errorf("Required() after window = true, want false")
+	}
+	tr.Fail("1.2.3.4")
+	if tr.Required("1.2.3.4") {
+		t.Errorf("Required() after 1 failure in new window = true, want false")
+	}
+}
+
+var challengeRE = regexp.MustCompile(`^pow nonce="([^"]+)", difficulty=(\d+)$`)
+
+func parseChallenge(t *testing.T, header string) (string, int) {
+	t.Helper()
+	m := challengeRE.FindStringSubmatch(header)
+	if m == nil {
+		t.Fatalf("Challenge() = %q, unexpected format", header)
+	}
+	difficulty, _ := strconv.Atoi(m[2])
+	return m[1], difficulty
+}
+
+func TestProofOfWork(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := NewProofOfWork([]byte("secret"), 8, time.Minute).WithClock(c)
+
+	header, err := p.Challenge("1.2.3.4")
+	if err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	nonce, difficulty := parseChallenge(t, header)
+	if difficulty != 8 {
+		t.Fatalf("difficulty = %d, want 8", difficulty)
+	}
+	solution := Solve(nonce, difficulty)
+
+	// In order: "valid" must run before "replayed".
+	tests := []struct {
+		name     string
+		client   string
+		solution string
+		want     error
+	}{
+		{name: "missing", client: "1.2.3.4", solution: "", want: ErrMissingSolution},
+		{name: "no counter", client: "1.2.3.4", solution: nonce, want: ErrInvalidSolution},
+		{name: "malformed nonce", client: "1.2.3.4", solution: "nodot:1", want: ErrInvalidSolution},
+		{name: "forged nonce", client: "1.2.3.4", solution: "AAAAAAAAAAAAAAAAAAAAAA.forged:1", want: ErrInvalidSolution},
+		{name: "other client", client: "5.6.7.8", solution: solution, want: ErrInvalidSolution},
+		{name: "insufficient work", client: "1.2.3.4", solution: unsolved(nonce, difficulty), want: ErrInvalidSolution},
+		{name: "valid", client: "1.2.3.4", solution: solution, want: nil},
+		{name: "replayed", client: "1.2.3.4", solution: solution, want: ErrReplayed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := p.Verify(tc.client, tc.solution); err != tc.want {
+				t.Errorf("Verify() error = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestProofOfWork_Expires(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := NewProofOfWork([]byte("secret"), 4, time.Minute).WithClock(c)
+
+	header, err := p.Challenge("1.2.3.4")
+	if err != nil {
+		t.Fatalf("Challenge() error = %v", err)
+	}
+	nonce, difficulty := parseChallenge(t, header)
+	c.Advance(time.Minute)
+	if err := p.Verify("1.2.3.4", Solve(nonce, difficulty)); err != ErrExpired {
+		t.Errorf("Verify() after ttl error = %v, want %v", err, ErrExpired)
+	}
+}
+
+// unsolved returns a "<nonce>:<counter>" that does not meet difficulty.
+func unsolved(nonce string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		s := nonce + ":" + strconv.Itoa(counter)
+		if LeadingZeroBits(sha256.Sum256([]byte(s))) < difficulty {
+			return s
+		}
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := map[string]struct {
+		sum  [sha256.Size]byte
+		want int
+	}{
+		"none":      {sum: [sha256.Size]byte{0x80}, want: 0},
+		"one byte":  {sum: [sha256.Size]byte{0x00, 0xff}, want: 8},
+		"partial":   {sum: [sha256.Size]byte{0x00, 0x0f}, want: 12},
+		"all zeros": {sum: [sha256.Size]byte{}, want: 256},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := LeadingZeroBits(tc.sum); got != tc.want {
+				t.Errorf("LeadingZeroBits() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
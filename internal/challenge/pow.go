@@ -0,0 +1,136 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// ProofOfWork is a hashcash-style Provider. The client must find a counter
+// such that SHA-256("<nonce>:<counter>") starts with difficulty zero bits
+// and send "<nonce>:<counter>" back. Nonces are stateless HMACs binding the
+// client and an expiry; only solved nonces are remembered, to stop reuse.
+type ProofOfWork struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	clock      clock.Clock
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+const nonceRandomLen = 8
+
+// NewProofOfWork returns a ProofOfWork signing nonces with secret. Each
+// extra bit of difficulty doubles the expected work; 20 bits takes about a
+// second in a browser.
+func NewProofOfWork(secret []byte, difficulty int, ttl time.Duration) *ProofOfWork {
+	return &ProofOfWork{
+		secret:     secret,
+		difficulty: difficulty,
+		ttl:        ttl,
+		clock:      clock.Real,
+		used:       map[string]time.Time{},
+	}
+}
+
+// WithClock makes p read time from c. It must be called before p is used.
+func (p *ProofOfWork) WithClock(c clock.Clock) *ProofOfWork {
+	p.clock = c
+	return p
+}
+
+func (p *ProofOfWork) Challenge(client string) (string, error) {
+	payload := make([]byte, 8+nonceRandomLen)
+	binary.BigEndian.PutUint64(payload, uint64(p.clock.Now().Add(p.ttl).Unix())) // #nosec G115 -- Unix time is positive
+	if _, err := rand.Read(payload[8:]); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(payload) + "." + p.sign(client, payload)
+	return fmt.Sprintf("pow nonce=%q, difficulty=%d", nonce, p.difficulty), nil
+}
+
+func (p *ProofOfWork) Verify(client, solution string) error {
+	if solution == "" {
+		return ErrMissingSolution
+	}
+	nonce, _, ok := strings.Cut(solution, ":")
+	if !ok {
+		return ErrInvalidSolution
+	}
+	encoded, sig, ok := strings.Cut(nonce, ".")
+	if !ok {
+		return ErrInvalidSolution
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(payload) != 8+nonceRandomLen {
+		return ErrInvalidSolution
+	}
+	if !hmac.Equal([]byte(sig), []byte(p.sign(client, payload))) {
+		return ErrInvalidSolution
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0) // #nosec G115 -- signed by us
+	now := p.clock.Now()
+	if !now.Before(expires) {
+		return ErrExpired
+	}
+	if LeadingZeroBits(sha256.Sum256([]byte(solution))) < p.difficulty {
+		return ErrInvalidSolution
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for n, exp := range p.used {
+		if !now.Before(exp) {
+			delete(p.used, n)
+		}
+	}
+	if _, ok := p.used[nonce]; ok {
+		return ErrReplayed
+	}
+	p.used[nonce] = expires
+	return nil
+}
+
+func (p *ProofOfWork) sign(client string, payload []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(client))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// LeadingZeroBits counts the zero bits at the start of sum.
+func LeadingZeroBits(sum [sha256.Size]byte) int {
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Solve finds a solution to a ProofOfWork challenge, given the nonce and
+// difficulty from its Header. It is what a client runs, and is used by
+// tests.
+func Solve(nonce string, difficulty int) string {
+	for counter := 0; ; counter++ {
+		solution := nonce + ":" + strconv.Itoa(counter)
+		if LeadingZeroBits(sha256.Sum256([]byte(solution))) >= difficulty {
+			return solution
+		}
+	}
+}
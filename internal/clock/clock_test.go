@@ -0,0 +1,35 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("Now() = %v, want %v", got, start)
+	}
+	c.Advance(time.Hour)
+	if got := c.Now(); !got.Equal(start.Add(time.Hour)) {
+		t.Errorf("Now() after Advance = %v", got)
+	}
+	c.Advance(-2 * time.Hour)
+	if got := c.Now(); !got.Equal(start.Add(-time.Hour)) {
+		t.Errorf("Now() after negative Advance = %v", got)
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set = %v", got)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Real.Now() = %v, outside of call window", got)
+	}
+}
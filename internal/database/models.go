@@ -4,7 +4,24 @@
 
 package database
 
-import ()
+import (
+	"database/sql"
+)
+
+type ApiKey struct {
+	ID         string
+	CreatedAt  string
+	UpdatedAt  string
+	UserID     string
+	Name       string
+	KeyHash    string
+	KeyHint    string
+	LastUsedAt sql.NullString
+	RevokedAt  sql.NullString
+	Scopes     string
+	CreatedVia string
+	CreatedBy  sql.NullString
+}
 
 type Note struct {
 	ID        string
@@ -14,10 +31,20 @@ type Note struct {
 	UserID    string
 }
 
+type OneTimeToken struct {
+	TokenHash  string
+	Purpose    string
+	Subject    string
+	CreatedAt  string
+	ExpiresAt  string
+	ConsumedAt sql.NullString
+}
+
 type User struct {
-	ID        string
-	CreatedAt string
-	UpdatedAt string
-	Name      string
-	ApiKey    string
+	ID              string
+	CreatedAt       string
+	UpdatedAt       string
+	Name            string
+	ApiKey          string
+	ApiKeyRevokedAt sql.NullString
 }
@@ -33,6 +33,19 @@ func (q *Queries) CreateNote(ctx context.Context, arg CreateNoteParams) error {
 	return err
 }
 
+const deleteNotesForUser = `-- name: DeleteNotesForUser :execrows
+
+DELETE FROM notes WHERE user_id = ?
+`
+
+func (q *Queries) DeleteNotesForUser(ctx context.Context, userID string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteNotesForUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getNote = `-- name: GetNote :one
 
 SELECT id, created_at, updated_at, note, user_id FROM notes WHERE id = ?
@@ -7,6 +7,7 @@ package database
 
 import (
 	"context"
+	"database/sql"
 )
 
 const createUser = `-- name: CreateUser :exec
@@ -39,9 +40,22 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) error {
 	return err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+
+DELETE FROM users WHERE id = ?
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUser = `-- name: GetUser :one
 
-SELECT id, created_at, updated_at, name, api_key FROM users WHERE api_key = ?
+SELECT id, created_at, updated_at, name, api_key, api_key_revoked_at FROM users WHERE api_key = ?
 `
 
 func (q *Queries) GetUser(ctx context.Context, apiKey string) (User, error) {
@@ -53,6 +67,78 @@ func (q *Queries) GetUser(ctx context.Context, apiKey string) (User, error) {
 		&i.UpdatedAt,
 		&i.Name,
 		&i.ApiKey,
+		&i.ApiKeyRevokedAt,
 	)
 	return i, err
 }
+
+const listActiveUserKeysPage = `-- name: ListActiveUserKeysPage :many
+
+SELECT id, created_at, updated_at, name, api_key, api_key_revoked_at FROM users
+WHERE api_key_revoked_at IS NULL AND id > ?1
+    AND (?2 = '' OR id = ?2)
+    AND (?3 = '' OR created_at < ?3)
+ORDER BY id LIMIT ?4
+`
+
+type ListActiveUserKeysPageParams struct {
+	After         string
+	ID            string
+	CreatedBefore string
+	PageSize      int64
+}
+
+func (q *Queries) ListActiveUserKeysPage(ctx context.Context, arg ListActiveUserKeysPageParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveUserKeysPage,
+		arg.After,
+		arg.ID,
+		arg.CreatedBefore,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.ApiKey,
+			&i.ApiKeyRevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeAPIKey = `-- name: RevokeAPIKey :execrows
+
+UPDATE users SET api_key_revoked_at = ?, updated_at = ?
+WHERE api_key = ? AND api_key_revoked_at IS NULL
+`
+
+type RevokeAPIKeyParams struct {
+	ApiKeyRevokedAt sql.NullString
+	UpdatedAt       string
+	ApiKey          string
+}
+
+func (q *Queries) RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAPIKey, arg.ApiKeyRevokedAt, arg.UpdatedAt, arg.ApiKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,79 @@
+// Package deprecation announces the retirement of legacy behavior to
+// clients still relying on it, with the Deprecation (RFC 9745), Sunset
+// (RFC 8594) and Link response headers.
+package deprecation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Notice is the retirement plan for one legacy behavior.
+type Notice struct {
+	// Deprecated is when the behavior was, or will be, deprecated.
+	Deprecated time.Time
+	// Sunset is when the behavior will stop working.
+	Sunset time.Time
+}
+
+// Schedule maps a legacy behavior's name to its notice.
+type Schedule map[string]Notice
+
+// ParseSchedule parses a comma-separated list of name=deprecated/sunset
+// entries, with dates as YYYY-MM-DD in UTC, e.g.
+// "lenient_parsing=2026-10-01/2027-04-01". The empty string is an empty
+// schedule.
+func ParseSchedule(s string) (Schedule, error) {
+	sched := Schedule{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, dates, ok := strings.Cut(entry, "=")
+		from, to, ok2 := strings.Cut(dates, "/")
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("deprecation entry %q: want name=YYYY-MM-DD/YYYY-MM-DD", entry)
+		}
+		deprecated, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return nil, fmt.Errorf("deprecation entry %q: %w", entry, err)
+		}
+		sunset, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return nil, fmt.Errorf("deprecation entry %q: %w", entry, err)
+		}
+		if sunset.Before(deprecated) {
+			return nil, fmt.Errorf("deprecation entry %q: sunset is before deprecation", entry)
+		}
+		sched[name] = Notice{Deprecated: deprecated, Sunset: sunset}
+	}
+	return sched, nil
+}
+
+// Apply sets the deprecation headers on h for a response to a request that
+// used the named behaviors. Names not in the schedule are ignored. A
+// response can only carry one Sunset, so when several behaviors were used
+// the one retiring first is announced. link, if set, is added as a Link
+// to documentation on migrating.
+func (s Schedule) Apply(h http.Header, link string, used ...string) {
+	var notice Notice
+	found := false
+	for _, name := range used {
+		n, ok := s[name]
+		if ok && (!found || n.Sunset.Before(notice.Sunset)) {
+			notice, found = n, true
+		}
+	}
+	if !found {
+		return
+	}
+
+	h.Set("Deprecation", fmt.Sprintf("@%d", notice.Deprecated.Unix()))
+	h.Set("Sunset", notice.Sunset.UTC().Format(http.TimeFormat))
+	if link != "" {
+		h.Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", link))
+	}
+}
@@ -0,0 +1,59 @@
+package deprecation
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"empty", "", 0, false},
+		{"one", "lenient_parsing=2026-10-01/2027-04-01", 1, false},
+		{"two with spaces", "a=2026-01-01/2026-06-01, b=2026-02-01/2026-03-01", 2, false},
+		{"missing dates", "a", 0, true},
+		{"missing sunset", "a=2026-01-01", 0, true},
+		{"bad date", "a=2026-13-01/2027-01-01", 0, true},
+		{"sunset first", "a=2027-01-01/2026-01-01", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSchedule(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseSchedule() = %v, want %d entries", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedule_Apply(t *testing.T) {
+	sched, err := ParseSchedule("late=2026-01-01/2027-06-01,early=2026-01-01/2027-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := http.Header{}
+	sched.Apply(h, "https://example.com/migrate", "late", "early", "unscheduled")
+	if got := h.Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("Deprecation = %q, want @1767225600", got)
+	}
+	if got := h.Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("Sunset = %q, want the earliest sunset", got)
+	}
+	if got := h.Get("Link"); got != `<https://example.com/migrate>; rel="deprecation"` {
+		t.Errorf("Link = %q", got)
+	}
+
+	h = http.Header{}
+	sched.Apply(h, "", "unscheduled")
+	if len(h) != 0 {
+		t.Errorf("Apply() for unscheduled behavior set %v", h)
+	}
+}
@@ -0,0 +1,91 @@
+// Package i18n translates user-facing messages. Messages are identified by
+// their English source text, gettext style, so untranslated messages fall
+// back to readable English.
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceLanguage is the language message IDs are written in.
+const SourceLanguage = "en"
+
+// Catalog maps a language tag to translations keyed by message ID.
+type Catalog map[string]map[string]string
+
+// Languages returns the languages c can translate into, including
+// SourceLanguage.
+func (c Catalog) Languages() []string {
+	langs := []string{SourceLanguage}
+	for lang := range c {
+		if lang != SourceLanguage {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Message returns the translation of msgID into lang, or msgID itself if
+// there is none.
+func (c Catalog) Message(lang, msgID string) string {
+	if msg, ok := c[lang][msgID]; ok {
+		return msg
+	}
+	return msgID
+}
+
+// Negotiate picks the best of supported for an Accept-Language header
+// value. Tags match exactly or by primary subtag ("de-AT" matches "de").
+// If nothing matches, supported[0] is returned.
+func Negotiate(acceptLanguage string, supported []string) string {
+	best, bestQ := supported[0], 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, q := parseRange(part)
+		if q <= bestQ {
+			continue
+		}
+		if lang, ok := match(tag, supported); ok {
+			best, bestQ = lang, q
+		}
+	}
+	return best
+}
+
+// parseRange splits one Accept-Language element into its lowercased tag
+// and quality. Malformed qualities count as 0, i.e. not acceptable.
+func parseRange(part string) (string, float64) {
+	tag, params, _ := strings.Cut(part, ";")
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || name != "q" {
+			continue
+		}
+		var err error
+		q, err = strconv.ParseFloat(value, 64)
+		if err != nil || q < 0 || q > 1 {
+			q = 0
+		}
+	}
+	return tag, q
+}
+
+func match(tag string, supported []string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	if tag == "*" {
+		return supported[0], true
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	for _, lang := range supported {
+		if l := strings.ToLower(lang); l == tag || l == primary {
+			return lang, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,55 @@
+package i18n
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{"en", "de", "fr"}
+	tests := map[string]struct {
+		header string
+		want   string
+	}{
+		"empty":               {header: "", want: "en"},
+		"exact":               {header: "de", want: "de"},
+		"primary subtag":      {header: "fr-CA", want: "fr"},
+		"case insensitive":    {header: "DE-at", want: "de"},
+		"first listed":        {header: "fr, de", want: "fr"},
+		"highest quality":     {header: "de;q=0.5, fr;q=0.8", want: "fr"},
+		"unsupported skipped": {header: "ja, de;q=0.3", want: "de"},
+		"nothing supported":   {header: "ja, zh", want: "en"},
+		"wildcard":            {header: "ja, *;q=0.1", want: "en"},
+		"zero quality":        {header: "de;q=0", want: "en"},
+		"malformed quality":   {header: "de;q=high, fr;q=0.2", want: "fr"},
+		"whitespace":          {header: " de ; q=0.9 ", want: "de"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := Negotiate(tc.header, supported)
+			if got != tc.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tc.header, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	c := Catalog{
+		"de": {"Hello": "Hallo"},
+		"fr": {"Hello": "Bonjour"},
+	}
+
+	if got := c.Message("de", "Hello"); got != "Hallo" {
+		t.Errorf("Message(de) = %q", got)
+	}
+	if got := c.Message("de", "Goodbye"); got != "Goodbye" {
+		t.Errorf("Message(de, untranslated) = %q", got)
+	}
+	if got := c.Message("en", "Hello"); got != "Hello" {
+		t.Errorf("Message(en) = %q", got)
+	}
+
+	langs := c.Languages()
+	if len(langs) != 3 || langs[0] != "en" || langs[1] != "de" || langs[2] != "fr" {
+		t.Errorf("Languages() = %v", langs)
+	}
+}
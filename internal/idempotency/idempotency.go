@@ -0,0 +1,243 @@
+// Package idempotency remembers responses to requests carrying an
+// Idempotency-Key header so that client retries are answered with the
+// original response instead of repeating the side effect.
+package idempotency
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// Header is the request header clients set to a unique value per operation.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses served from the store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+var (
+	// ErrInProgress means a request with the same key hasn't finished yet.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrKeyReused means the key was first used for a different request.
+	ErrKeyReused = errors.New("idempotency key was used for a different request")
+	// ErrFull means the caller, or the store as a whole, already holds as
+	// many keys as it may.
+	ErrFull = errors.New("too many idempotency keys in use")
+)
+
+// sweepInterval is how often expired entries are dropped, so that they
+// stop counting towards the store's limits.
+const sweepInterval = time.Minute
+
+// Response is a stored response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Store keeps responses in memory for ttl. Keys are grouped by scope, the
+// caller they belong to, and each scope may hold at most maxPerScope keys
+// so that one caller can't use up the store. The store as a whole holds at
+// most maxEntries. Keys beyond either limit are rejected rather than
+// evicting others, which could let a retry repeat its side effect.
+type Store struct {
+	ttl         time.Duration
+	maxPerScope int
+	maxEntries  int
+	clock       clock.Clock
+
+	mu        sync.Mutex
+	scopes    map[string]map[string]*entry
+	entries   int
+	nextSweep time.Time
+}
+
+type entry struct {
+	request  string
+	response *Response
+	expires  time.Time
+}
+
+// NewStore returns an empty store keeping responses for ttl, with at most
+// maxPerScope keys per scope and maxEntries in total.
+func NewStore(ttl time.Duration, maxPerScope, maxEntries int) *Store {
+	return &Store{
+		ttl:         ttl,
+		maxPerScope: maxPerScope,
+		maxEntries:  maxEntries,
+		clock:       clock.Real,
+		scopes:      map[string]map[string]*entry{},
+	}
+}
+
+// WithClock makes s read time from c. It must be called before s is used.
+func (s *Store) WithClock(c clock.Clock) *Store {
+	s.clock = c
+	return s
+}
+
+// Begin claims key in scope, e.g. the caller's identity ID, for request, a
+// description of the request such as method and path. It returns the
+// stored response if the request already completed, ErrInProgress if it is
+// still running, ErrKeyReused if key belongs to a different request, and
+// ErrFull if a new key would exceed the store's limits. A nil response and
+// error means the caller owns the key and must call Complete or Abort.
+func (s *Store) Begin(scope, key, request string) (*Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	s.sweep(now)
+
+	keys := s.scopes[scope]
+	e, ok := keys[key]
+	if ok && now.Before(e.expires) {
+		switch {
+		case e.request != request:
+			return nil, ErrKeyReused
+		case e.response == nil:
+			return nil, ErrInProgress
+		}
+		return e.response, nil
+	}
+
+	if !ok {
+		if len(keys) >= s.maxPerScope {
+			s.sweepScope(scope, now)
+			keys = s.scopes[scope]
+		}
+		if len(keys) >= s.maxPerScope || s.entries >= s.maxEntries {
+			return nil, ErrFull
+		}
+		if keys == nil {
+			keys = map[string]*entry{}
+			s.scopes[scope] = keys
+		}
+		s.entries++
+	}
+	keys[key] = &entry{request: request, expires: now.Add(s.ttl)}
+	return nil, nil
+}
+
+// Complete stores resp for key in scope.
+func (s *Store) Complete(scope, key string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.scopes[scope][key]; ok {
+		e.response = &resp
+		e.expires = s.clock.Now().Add(s.ttl)
+	}
+}
+
+// Abort releases key in scope without storing a response, so the request
+// can be retried for real.
+func (s *Store) Abort(scope, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(scope, key)
+}
+
+// Len returns the number of keys held, including expired ones not yet
+// swept.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries
+}
+
+func (s *Store) delete(scope, key string) {
+	keys := s.scopes[scope]
+	if _, ok := keys[key]; !ok {
+		return
+	}
+	delete(keys, key)
+	s.entries--
+	if len(keys) == 0 {
+		delete(s.scopes, scope)
+	}
+}
+
+func (s *Store) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for scope := range s.scopes {
+		s.sweepScope(scope, now)
+	}
+	s.nextSweep = now.Add(sweepInterval)
+}
+
+func (s *Store) sweepScope(scope string, now time.Time) {
+	for key, e := range s.scopes[scope] {
+		if !now.Before(e.expires) {
+			s.delete(scope, key)
+		}
+	}
+}
+
+// Recorder is an http.ResponseWriter that passes writes through while
+// keeping a copy of the response, up to maxBody bytes of body.
+type Recorder struct {
+	http.ResponseWriter
+	maxBody    int
+	statusCode int
+	body       bytes.Buffer
+	overflow   bool
+}
+
+// NewRecorder wraps w.
+func NewRecorder(w http.ResponseWriter, maxBody int) *Recorder {
+	return &Recorder{ResponseWriter: w, maxBody: maxBody}
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	if r.statusCode == 0 {
+		r.statusCode = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *Recorder) Write(p []byte) (int, error) {
+	if r.statusCode == 0 {
+		r.statusCode = http.StatusOK
+	}
+	if r.body.Len()+len(p) > r.maxBody {
+		r.overflow = true
+	} else {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Response returns the recorded response. ok is false if the body exceeded
+// maxBody and so can't be replayed faithfully.
+func (r *Recorder) Response() (resp Response, ok bool) {
+	if r.overflow {
+		return Response{}, false
+	}
+	code := r.statusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return Response{
+		StatusCode: code,
+		Header:     r.Header().Clone(),
+		Body:       bytes.Clone(r.body.Bytes()),
+	}, true
+}
+
+// Replay writes a stored response to w.
+func Replay(w http.ResponseWriter, resp *Response) error {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(resp.StatusCode)
+	_, err := w.Write(resp.Body)
+	return err
+}
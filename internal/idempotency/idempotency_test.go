@@ -0,0 +1,129 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func newTestStore() (*Store, *clock.Fake) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := clock.NewFake(now)
+	return NewStore(time.Hour, 2, 3).WithClock(c), c
+}
+
+func TestStore_Lifecycle(t *testing.T) {
+	s, _ := newTestStore()
+
+	if resp, err := s.Begin("user-1", "abc", "POST /v1/notes"); resp != nil || err != nil {
+		t.Fatalf("first Begin() = %v, %v, want nil, nil", resp, err)
+	}
+	if _, err := s.Begin("user-1", "abc", "POST /v1/notes"); err != ErrInProgress {
+		t.Errorf("Begin() while in flight error = %v, want %v", err, ErrInProgress)
+	}
+	if _, err := s.Begin("user-1", "abc", "POST /v1/users"); err != ErrKeyReused {
+		t.Errorf("Begin() for other request error = %v, want %v", err, ErrKeyReused)
+	}
+
+	s.Complete("user-1", "abc", Response{StatusCode: http.StatusCreated, Body: []byte(`{"id":"1"}`)})
+	resp, err := s.Begin("user-1", "abc", "POST /v1/notes")
+	if err != nil || resp == nil || resp.StatusCode != http.StatusCreated || string(resp.Body) != `{"id":"1"}` {
+		t.Errorf("Begin() after Complete = %+v, %v, want stored response", resp, err)
+	}
+
+	if resp, err := s.Begin("user-2", "abc", "POST /v1/notes"); resp != nil || err != nil {
+		t.Errorf("Begin() for another scope = %v, %v, want nil, nil", resp, err)
+	}
+}
+
+func TestStore_AbortAllowsRetry(t *testing.T) {
+	s, _ := newTestStore()
+
+	_, _ = s.Begin("user-1", "k", "POST /v1/notes")
+	s.Abort("user-1", "k")
+	if resp, err := s.Begin("user-1", "k", "POST /v1/notes"); resp != nil || err != nil {
+		t.Errorf("Begin() after Abort = %v, %v, want nil, nil", resp, err)
+	}
+}
+
+func TestStore_Expiry(t *testing.T) {
+	s, now := newTestStore()
+
+	_, _ = s.Begin("user-1", "k", "POST /v1/notes")
+	s.Complete("user-1", "k", Response{StatusCode: http.StatusCreated})
+	now.Advance(2 * time.Hour)
+
+	if resp, err := s.Begin("user-1", "k", "POST /v1/notes"); resp != nil || err != nil {
+		t.Errorf("Begin() after TTL = %v, %v, want nil, nil", resp, err)
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len() = %d, want expired entries swept", s.Len())
+	}
+}
+
+func TestStore_Limits(t *testing.T) {
+	s, now := newTestStore()
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.Begin("user-1", key, "POST /v1/notes"); err != nil {
+			t.Fatalf("Begin(%s) error = %v", key, err)
+		}
+	}
+	if _, err := s.Begin("user-1", "c", "POST /v1/notes"); err != ErrFull {
+		t.Errorf("Begin() over the per-scope limit error = %v, want %v", err, ErrFull)
+	}
+	if _, err := s.Begin("user-1", "a", "POST /v1/notes"); err != ErrInProgress {
+		t.Errorf("Begin() for a held key at the limit error = %v, want %v", err, ErrInProgress)
+	}
+
+	if _, err := s.Begin("user-2", "a", "POST /v1/notes"); err != nil {
+		t.Fatalf("Begin() for another scope error = %v", err)
+	}
+	if _, err := s.Begin("user-3", "a", "POST /v1/notes"); err != ErrFull {
+		t.Errorf("Begin() over the total limit error = %v, want %v", err, ErrFull)
+	}
+
+	s.Abort("user-2", "a")
+	if _, err := s.Begin("user-3", "a", "POST /v1/notes"); err != nil {
+		t.Errorf("Begin() after Abort freed a key error = %v", err)
+	}
+
+	now.Advance(2 * time.Hour)
+	if _, err := s.Begin("user-1", "c", "POST /v1/notes"); err != nil {
+		t.Errorf("Begin() once held keys expired error = %v", err)
+	}
+}
+
+func TestRecorderAndReplay(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := NewRecorder(w, 64)
+	rec.Header().Set("Content-Type", "application/json")
+	rec.WriteHeader(http.StatusCreated)
+	_, _ = rec.Write([]byte(`{"id":"1"}`))
+
+	resp, ok := rec.Response()
+	if !ok || resp.StatusCode != http.StatusCreated || string(resp.Body) != `{"id":"1"}` {
+		t.Fatalf("Response() = %+v, %v", resp, ok)
+	}
+
+	replayed := httptest.NewRecorder()
+	if err := Replay(replayed, &resp); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != `{"id":"1"}` ||
+		replayed.Header().Get("Content-Type") != "application/json" || replayed.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("Replay() wrote %d %v %q", replayed.Code, replayed.Header(), replayed.Body.String())
+	}
+}
+
+func TestRecorder_Overflow(t *testing.T) {
+	rec := NewRecorder(httptest.NewRecorder(), 4)
+	_, _ = rec.Write([]byte("too long"))
+
+	if _, ok := rec.Response(); ok {
+		t.Errorf("Response() ok = true for body over maxBody")
+	}
+}
@@ -0,0 +1,65 @@
+// Package limit contains request limiters keyed by credential.
+package limit
+
+import "sync"
+
+// Concurrency caps the number of in-flight requests per key, so one key
+// can't occupy every worker with parallel long-running requests. A nil
+// *Concurrency allows everything.
+type Concurrency struct {
+	max int
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+// NewConcurrency returns a limiter allowing maxInFlight concurrent requests
+// per key. It returns nil, i.e. no limit, when maxInFlight is not positive.
+func NewConcurrency(maxInFlight int) *Concurrency {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &Concurrency{
+		max:      maxInFlight,
+		inFlight: map[string]int{},
+	}
+}
+
+// Acquire takes a slot for key without blocking. If ok is true the caller
+// must call release exactly once when the request finishes.
+func (c *Concurrency) Acquire(key string) (release func(), ok bool) {
+	if c == nil {
+		return func() {}, true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inFlight[key] >= c.max {
+		return nil, false
+	}
+	c.inFlight[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.release(key) })
+	}, true
+}
+
+// InFlight returns the number of slots currently held for key.
+func (c *Concurrency) InFlight(key string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight[key]
+}
+
+func (c *Concurrency) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight[key]--
+	if c.inFlight[key] <= 0 {
+		delete(c.inFlight, key)
+	}
+}
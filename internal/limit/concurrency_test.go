@@ -0,0 +1,88 @@
+package limit
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrency_Acquire(t *testing.T) {
+	c := NewConcurrency(2)
+
+	release1, ok := c.Acquire("a")
+	if !ok {
+		t.Fatalf("first Acquire() ok = false")
+	}
+	release2, ok := c.Acquire("a")
+	if !ok {
+		t.Fatalf("second Acquire() ok = false")
+	}
+	if _, ok := c.Acquire("a"); ok {
+		t.Errorf("third Acquire() ok = true, want false at limit")
+	}
+	if _, ok := c.Acquire("b"); !ok {
+		t.Errorf("Acquire() for another key ok = false, want true")
+	}
+
+	release1()
+	release1()
+	if got := c.InFlight("a"); got != 1 {
+		t.Errorf("InFlight() after double release = %d, want 1", got)
+	}
+	if _, ok := c.Acquire("a"); !ok {
+		t.Errorf("Acquire() after release ok = false, want true")
+	}
+	release2()
+}
+
+func TestConcurrency_ReleaseForgetsIdleKeys(t *testing.T) {
+	c := NewConcurrency(1)
+	release, _ := c.Acquire("a")
+	release()
+
+	if len(c.inFlight) != 0 {
+		t.Errorf("inFlight has %d entries after release, want 0", len(c.inFlight))
+	}
+}
+
+func TestConcurrency_Disabled(t *testing.T) {
+	c := NewConcurrency(0)
+	if c != nil {
+		t.Fatalf("NewConcurrency(0) = %v, want nil", c)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := c.Acquire("a"); !ok {
+			t.Fatalf("nil limiter rejected a request")
+		}
+	}
+}
+
+func TestConcurrency_Parallel(t *testing.T) {
+	const limit = 5
+	c := NewConcurrency(limit)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	peak := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, ok := c.Acquire("k")
+			if !ok {
+				return
+			}
+			defer release()
+			mu.Lock()
+			peak = max(peak, c.InFlight("k"))
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if peak > limit {
+		t.Errorf("peak in-flight = %d, want <= %d", peak, limit)
+	}
+	if got := c.InFlight("k"); got != 0 {
+		t.Errorf("InFlight() after all releases = %d, want 0", got)
+	}
+}
@@ -0,0 +1,255 @@
+// Package migrate applies the versioned SQL migrations in sql/schema. It
+// reads goose's file annotations and keeps goose's version table, so a
+// database can be migrated with either tool.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versionTable is goose's bookkeeping table.
+const versionTable = "goose_db_version"
+
+var ErrNoMigrations = errors.New("no migrations to roll back")
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      []string
+	Down    []string
+}
+
+// Status is a migration and whether it is applied.
+type Status struct {
+	Migration
+	Applied bool
+}
+
+// Parse reads migrations named like 001_users.sql from the root of fsys,
+// in version order. Each file has a "-- +goose Up" section and optionally
+// a "-- +goose Down" section; statements end with a line ending in ";".
+func Parse(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	seen := map[int64]string{}
+	for _, name := range names {
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name has no version prefix", name)
+		}
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", name, prefix)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, name, version)
+		}
+		seen[version] = name
+
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		up, down, err := parseSections(string(src))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    strings.TrimSuffix(path.Base(name), ".sql"),
+			Up:      up,
+			Down:    down,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func parseSections(src string) (up, down []string, err error) {
+	var section *[]string
+	var stmt strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch trimmed {
+		case "-- +goose Up":
+			section = &up
+			continue
+		case "-- +goose Down":
+			section = &down
+			continue
+		}
+		if section == nil {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, nil, errors.New("statement before -- +goose Up")
+			}
+			continue
+		}
+		if stmt.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+		stmt.WriteString(line)
+		stmt.WriteByte('\n')
+		if strings.HasSuffix(trimmed, ";") {
+			*section = append(*section, strings.TrimSpace(stmt.String()))
+			stmt.Reset()
+		}
+	}
+	if stmt.Len() > 0 {
+		return nil, nil, errors.New("unterminated statement")
+	}
+	if up == nil {
+		return nil, nil, errors.New("missing -- +goose Up section")
+	}
+	return up, down, nil
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for migrations, as returned by Parse.
+func New(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Status reports every known migration and whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Migration: mig, Applied: applied[mig.Version]}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied. Each migration runs in its own transaction.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		err := m.run(ctx, mig.Up, "INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (?, 1)", mig.Version)
+		if err != nil {
+			return done, fmt.Errorf("apply %s: %w", mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down rolls back the most recent applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return Migration{}, err
+	}
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if !applied[mig.Version] {
+			continue
+		}
+		err := m.run(ctx, mig.Down, "DELETE FROM "+versionTable+" WHERE version_id = ?", mig.Version)
+		if err != nil {
+			return mig, fmt.Errorf("roll back %s: %w", mig.Name, err)
+		}
+		return mig, nil
+	}
+	return Migration{}, ErrNoMigrations
+}
+
+func (m *Migrator) run(ctx context.Context, stmts []string, record string, version int64) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once Commit has succeeded.
+	defer func() { _ = tx.Rollback() }()
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applied returns the set of applied versions, creating the version table
+// first if needed.
+func (m *Migrator) applied(ctx context.Context) (map[int64]bool, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+versionTable+` (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied FROM "+versionTable+" ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []versionRecord
+	for rows.Next() {
+		var r versionRecord
+		if err := rows.Scan(&r.version, &r.applied); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return appliedVersions(records), nil
+}
+
+type versionRecord struct {
+	version int64
+	applied bool
+}
+
+// appliedVersions reduces version table rows, newest first, to the set of
+// applied versions. As in goose, the newest row for a version wins.
+func appliedVersions(records []versionRecord) map[int64]bool {
+	applied := map[int64]bool{}
+	seen := map[int64]bool{}
+	for _, r := range records {
+		if seen[r.version] {
+			continue
+		}
+		seen[r.version] = true
+		if r.applied && r.version > 0 {
+			applied[r.version] = true
+		}
+	}
+	return applied
+}
@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_notes.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE notes (
+    id TEXT PRIMARY KEY
+);
+CREATE INDEX notes_id ON notes (id);
+
+-- +goose Down
+DROP TABLE notes;
+`)},
+		"001_users.sql": {Data: []byte(`-- +goose Up
+-- the first table
+CREATE TABLE users (id TEXT PRIMARY KEY);
+`)},
+		"README.md": {Data: []byte("not a migration")},
+	}
+
+	got, err := Parse(fsys)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Migration{
+		{
+			Version: 1,
+			Name:    "001_users",
+			Up:      []string{"CREATE TABLE users (id TEXT PRIMARY KEY);"},
+		},
+		{
+			Version: 2,
+			Name:    "002_notes",
+			Up: []string{
+				"CREATE TABLE notes (\n    id TEXT PRIMARY KEY\n);",
+				"CREATE INDEX notes_id ON notes (id);",
+			},
+			Down: []string{"DROP TABLE notes;"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]struct {
+		files   fstest.MapFS
+		wantErr string
+	}{
+		"no version prefix": {
+			files:   fstest.MapFS{"users.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")}},
+			wantErr: "no version prefix",
+		},
+		"bad version": {
+			files:   fstest.MapFS{"abc_users.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")}},
+			wantErr: "invalid version",
+		},
+		"duplicate version": {
+			files: fstest.MapFS{
+				"001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+				"1_b.sql":   {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+			},
+			wantErr: "share version 1",
+		},
+		"no up section": {
+			files:   fstest.MapFS{"001_a.sql": {Data: []byte("-- +goose Down\nSELECT 1;\n")}},
+			wantErr: "missing -- +goose Up",
+		},
+		"statement before up": {
+			files:   fstest.MapFS{"001_a.sql": {Data: []byte("SELECT 1;\n-- +goose Up\nSELECT 1;\n")}},
+			wantErr: "before -- +goose Up",
+		},
+		"unterminated statement": {
+			files:   fstest.MapFS{"001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1\n")}},
+			wantErr: "unterminated",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.files)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Parse() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppliedVersions(t *testing.T) {
+	// Newest first, as read from the version table.
+	records := []versionRecord{
+		{version: 3, applied: false},
+		{version: 2, applied: true},
+		{version: 3, applied: true},
+		{version: 1, applied: true},
+		{version: 0, applied: true},
+	}
+	got := appliedVersions(records)
+	want := map[int64]bool{1: true, 2: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("appliedVersions() = %v, want %v", got, want)
+	}
+}
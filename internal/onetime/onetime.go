@@ -0,0 +1,132 @@
+// Package onetime issues single-use tokens for flows such as password
+// reset, magic links and invites. A token is bound to a purpose and a
+// subject, expires after a TTL, and can be consumed at most once.
+package onetime
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// ErrInvalidToken is returned for a token that doesn't exist, has expired,
+// was issued for another purpose or has already been used. They are
+// deliberately indistinguishable to the caller.
+var ErrInvalidToken = errors.New("token is invalid, expired or already used")
+
+// Record is a token as stored. Only the token's hash is kept.
+type Record struct {
+	Hash      string
+	Purpose   string
+	Subject   string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Store persists tokens. Consume must be atomic: of any number of
+// concurrent calls for the same hash, at most one may succeed, and it
+// must fail with ErrInvalidToken if the record is missing, consumed, for
+// another purpose or expired at now.
+type Store interface {
+	Create(ctx context.Context, rec Record) error
+	Consume(ctx context.Context, hash, purpose string, now time.Time) (subject string, err error)
+}
+
+// Issuer creates and redeems tokens against a Store.
+type Issuer struct {
+	store Store
+	clock clock.Clock
+}
+
+// NewIssuer returns an Issuer backed by store.
+func NewIssuer(store Store) *Issuer {
+	return &Issuer{store: store, clock: clock.Real}
+}
+
+// WithClock makes i read time from c. It must be called before i is used.
+func (i *Issuer) WithClock(c clock.Clock) *Issuer {
+	i.clock = c
+	return i
+}
+
+// Issue returns a new token for subject, valid for purpose until ttl
+// passes. The token is only returned here; the store keeps its hash.
+func (i *Issuer) Issue(ctx context.Context, purpose, subject string, ttl time.Duration) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := i.clock.Now()
+	err := i.store.Create(ctx, Record{
+		Hash:      Hash(token),
+		Purpose:   purpose,
+		Subject:   subject,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Consume redeems token for purpose and returns its subject. It succeeds
+// at most once per token.
+func (i *Issuer) Consume(ctx context.Context, purpose, token string) (string, error) {
+	return i.store.Consume(ctx, Hash(token), purpose, i.clock.Now())
+}
+
+// Hash is the hex SHA-256 under which token is stored.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryStore is a Store for tests and single-instance use. Consumed and
+// expired records are deleted.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Create(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.records[rec.Hash]; ok {
+		return errors.New("token already exists")
+	}
+	s.records[rec.Hash] = rec
+	return nil
+}
+
+func (s *MemoryStore) Consume(_ context.Context, hash, purpose string, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, rec := range s.records {
+		if !now.Before(rec.ExpiresAt) {
+			delete(s.records, h)
+		}
+	}
+
+	rec, ok := s.records[hash]
+	if !ok || rec.Purpose != purpose {
+		return "", ErrInvalidToken
+	}
+	delete(s.records, hash)
+	return rec.Subject, nil
+}
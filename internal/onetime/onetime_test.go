@@ -0,0 +1,82 @@
+package onetime
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func newTestIssuer() (*Issuer, *clock.Fake) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return NewIssuer(NewMemoryStore()).WithClock(c), c
+}
+
+func TestIssuer_ConsumeOnce(t *testing.T) {
+	i, _ := newTestIssuer()
+	ctx := context.Background()
+
+	token, err := i.Issue(ctx, "password_reset", "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+	if subject, err := i.Consume(ctx, "password_reset", token); err != nil || subject != "user-1" {
+		t.Fatalf("Consume() = %q, %v, want user-1", subject, err)
+	}
+	if _, err := i.Consume(ctx, "password_reset", token); err != ErrInvalidToken {
+		t.Errorf("second Consume() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestIssuer_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		purpose string
+		token   func(string) string
+		advance time.Duration
+	}{
+		{"other purpose", "invite", func(tok string) string { return tok }, 0},
+		{"unknown token", "password_reset", func(string) string { return "nope" }, 0},
+		{"expired", "password_reset", func(tok string) string { return tok }, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i, now := newTestIssuer()
+			token, err := i.Issue(context.Background(), "password_reset", "user-1", time.Hour)
+			if err != nil {
+				t.Fatalf("Issue() error = %v", err)
+			}
+			now.Advance(tt.advance)
+			if _, err := i.Consume(context.Background(), tt.purpose, tt.token(token)); err != ErrInvalidToken {
+				t.Errorf("Consume() error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestIssuer_ConcurrentConsume(t *testing.T) {
+	i, _ := newTestIssuer()
+	token, err := i.Issue(context.Background(), "magic_link", "user-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var successes atomic.Int32
+	for n := 0; n < 16; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := i.Consume(context.Background(), "magic_link", token); err == nil {
+				successes.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if successes.Load() != 1 {
+		t.Errorf("%d concurrent Consume() calls succeeded, want 1", successes.Load())
+	}
+}
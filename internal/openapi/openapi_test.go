@@ -0,0 +1,77 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchemaOf(t *testing.T) {
+	type item struct {
+		ID        string            `json:"id"`
+		Count     int               `json:"count"`
+		Ratio     float64           `json:"ratio,omitempty"`
+		Enabled   bool              `json:"enabled"`
+		CreatedAt time.Time         `json:"created_at"`
+		DeletedAt *time.Time        `json:"deleted_at"`
+		Tags      []string          `json:"tags,omitempty"`
+		Labels    map[string]string `json:"labels,omitempty"`
+		Secret    string            `json:"-"`
+		Untagged  string
+		hidden    string
+	}
+	_ = item{}.hidden
+
+	want := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "string"},
+			"count":      {Type: "integer"},
+			"ratio":      {Type: "number"},
+			"enabled":    {Type: "boolean"},
+			"created_at": {Type: "string", Format: "date-time"},
+			"deleted_at": {Type: "string", Format: "date-time", Nullable: true},
+			"tags":       {Type: "array", Items: &Schema{Type: "string"}},
+			"labels":     {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"Untagged":   {Type: "string"},
+		},
+		Required: []string{"id", "count", "enabled", "created_at", "deleted_at", "Untagged"},
+	}
+
+	got := SchemaOf(item{})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SchemaOf() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSchemaOf_Embedded(t *testing.T) {
+	type base struct {
+		ID string `json:"id"`
+	}
+	type Tagged struct {
+		Name string `json:"name"`
+	}
+	type item struct {
+		base
+		Tagged `json:"tagged"`
+		Count  int `json:"count"`
+	}
+
+	got := SchemaOf(item{})
+	if got.Properties["id"] == nil || got.Properties["count"] == nil || got.Properties["tagged"] == nil || got.Properties["name"] != nil {
+		t.Errorf("SchemaOf() properties = %v, want id and count promoted and tagged nested", got.Properties)
+	}
+	if !reflect.DeepEqual(got.Required, []string{"id", "tagged", "count"}) {
+		t.Errorf("SchemaOf() required = %v", got.Required)
+	}
+}
+
+func TestSchemaOf_Slice(t *testing.T) {
+	type item struct {
+		ID string `json:"id"`
+	}
+	got := SchemaOf([]item{})
+	if got.Type != "array" || got.Items == nil || got.Items.Properties["id"] == nil {
+		t.Errorf("SchemaOf([]item) = %+v", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package secrets resolves secret values by name from pluggable sources,
+// so that where a secret is kept is deployment configuration rather than
+// code, and rotated secrets are picked up without a restart.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// ErrNotFound means a provider has no secret by that name.
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks secrets up by name. Lookup returns ErrNotFound, possibly
+// wrapped, when the secret isn't set.
+type Provider interface {
+	Lookup(ctx context.Context, name string) ([]byte, error)
+}
+
+// Env reads secrets from environment variables of the same name. An empty
+// variable counts as unset.
+type Env struct{}
+
+func (Env) Lookup(_ context.Context, name string) ([]byte, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+// Dir reads secrets from files named after them in a directory, as secret
+// managers mount them into containers. A trailing newline is dropped.
+type Dir string
+
+func (d Dir) Lookup(_ context.Context, name string) ([]byte, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("secret name %q: %w", name, ErrNotFound)
+	}
+	b, err := os.ReadFile(filepath.Join(string(d), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read secret %s: %w", name, err)
+	}
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	b = bytes.TrimSuffix(b, []byte("\r"))
+	if len(b) == 0 {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// Chain asks each provider in turn and returns the first secret found.
+type Chain []Provider
+
+func (c Chain) Lookup(ctx context.Context, name string) ([]byte, error) {
+	for _, p := range c {
+		v, err := p.Lookup(ctx, name)
+		if !errors.Is(err, ErrNotFound) {
+			return v, err
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Secret is one named secret, fetched on first use and fetched again once
+// refresh has passed. If a refresh fails the last value is kept, so that
+// an unavailable provider doesn't take down everything using the secret.
+// It is safe for concurrent use.
+type Secret struct {
+	provider Provider
+	name     string
+	refresh  time.Duration
+	clock    clock.Clock
+
+	mu      sync.Mutex
+	value   []byte
+	fetched time.Time
+}
+
+// New returns a Secret looking name up in p every refresh.
+func New(p Provider, name string, refresh time.Duration) *Secret {
+	return &Secret{provider: p, name: name, refresh: refresh, clock: clock.Real}
+}
+
+// WithClock makes s read time from c. It must be called before s is used.
+func (s *Secret) WithClock(c clock.Clock) *Secret {
+	s.clock = c
+	return s
+}
+
+// Name returns the name s is looked up by.
+func (s *Secret) Name() string {
+	return s.name
+}
+
+// Value returns the secret, looking it up if it was never fetched or
+// refresh has passed. Callers must not modify the returned slice.
+func (s *Secret) Value(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if s.value != nil && now.Sub(s.fetched) < s.refresh {
+		return s.value, nil
+	}
+	v, err := s.provider.Lookup(ctx, s.name)
+	if err != nil {
+		if s.value != nil {
+			return s.value, nil
+		}
+		return nil, fmt.Errorf("secret %s: %w", s.name, err)
+	}
+	s.value, s.fetched = v, now
+	return v, nil
+}
@@ -0,0 +1,128 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// mapProvider serves secrets from a map, failing with err when it is set.
+type mapProvider struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (m *mapProvider) Lookup(_ context.Context, name string) ([]byte, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	v, ok := m.values[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+func TestDir_Lookup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "SIGNING_SECRET"), []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "EMPTY"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		want    string
+		wantErr error
+	}{
+		{"trailing newline dropped", "SIGNING_SECRET", "s3cret", nil},
+		{"missing", "OTHER", "", ErrNotFound},
+		{"empty", "EMPTY", "", ErrNotFound},
+		{"path traversal", "../SIGNING_SECRET", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Dir(dir).Lookup(context.Background(), tt.secret)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Lookup() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Lookup() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChain_Lookup(t *testing.T) {
+	first := &mapProvider{values: map[string]string{"A": "from first"}}
+	second := &mapProvider{values: map[string]string{"A": "from second", "B": "from second"}}
+	c := Chain{first, second}
+
+	for name, want := range map[string]string{"A": "from first", "B": "from second"} {
+		got, err := c.Lookup(context.Background(), name)
+		if err != nil || string(got) != want {
+			t.Errorf("Lookup(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if _, err := c.Lookup(context.Background(), "C"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup(C) error = %v, want %v", err, ErrNotFound)
+	}
+
+	broken := errors.New("permission denied")
+	c = Chain{&mapProvider{err: broken}, second}
+	if _, err := c.Lookup(context.Background(), "A"); !errors.Is(err, broken) {
+		t.Errorf("Lookup() error = %v, want the first provider's failure", err)
+	}
+}
+
+func TestSecret_Refresh(t *testing.T) {
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	p := &mapProvider{values: map[string]string{"KEY": "v1"}}
+	s := New(p, "KEY", time.Minute).WithClock(c)
+	ctx := context.Background()
+
+	get := func() string {
+		t.Helper()
+		v, err := s.Value(ctx)
+		if err != nil {
+			t.Fatalf("Value() error = %v", err)
+		}
+		return string(v)
+	}
+
+	if got := get(); got != "v1" {
+		t.Fatalf("Value() = %q, want v1", got)
+	}
+	p.values["KEY"] = "v2"
+	if got := get(); got != "v1" || p.calls != 1 {
+		t.Errorf("Value() within refresh = %q after %d lookups, want cached v1", got, p.calls)
+	}
+
+	c.Advance(time.Minute)
+	if got := get(); got != "v2" {
+		t.Errorf("Value() after refresh = %q, want v2", got)
+	}
+
+	c.Advance(time.Minute)
+	p.err = errors.New("provider down")
+	if got := get(); got != "v2" {
+		t.Errorf("Value() with failing provider = %q, want last value v2", got)
+	}
+}
+
+func TestSecret_NeverFetched(t *testing.T) {
+	s := New(&mapProvider{}, "KEY", time.Minute)
+	if _, err := s.Value(context.Background()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Value() error = %v, want %v", err, ErrNotFound)
+	}
+}
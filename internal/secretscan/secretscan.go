@@ -0,0 +1,203 @@
+// Package secretscan implements the receiving side of GitHub's secret
+// scanning partner program: signature verification of leak reports and the
+// request/response payload types.
+package secretscan
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+// DefaultKeysURL serves the public keys GitHub signs reports with.
+const DefaultKeysURL = "https://api.github.com/meta/public_keys/secret_scanning"
+
+// Headers GitHub attaches to every report.
+const (
+	KeyIdentifierHeader = "Github-Public-Key-Identifier"
+	SignatureHeader     = "Github-Public-Key-Signature"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing secret scanning signature headers")
+	ErrUnknownKey       = errors.New("unknown secret scanning key identifier")
+	ErrInvalidSignature = errors.New("invalid secret scanning signature")
+)
+
+// Match is one leaked token reported by GitHub.
+type Match struct {
+	Token  string `json:"token"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// Label classifies a reported token in the response to GitHub.
+type Label string
+
+const (
+	TruePositive  Label = "true_positive"
+	FalsePositive Label = "false_positive"
+)
+
+// Result is the feedback returned to GitHub for one Match. Tokens are
+// identified by hash so the raw value is never echoed back.
+type Result struct {
+	TokenHash string `json:"token_hash"`
+	TokenType string `json:"token_type"`
+	Label     Label  `json:"label"`
+}
+
+// HashToken returns the hex SHA-256 digest GitHub expects in token_hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// refetchInterval is the least time between fetches of GitHub's keys.
+// Reports are unauthenticated until verified, so without it anyone could
+// make every request with a made-up key identifier trigger a fetch and use
+// up GitHub's rate limit for this host.
+const refetchInterval = time.Minute
+
+// Verifier checks report signatures against GitHub's published keys. Keys
+// are fetched lazily and refetched when an unknown identifier shows up, at
+// most once per refetchInterval, so GitHub's key rotation needs no restart.
+type Verifier struct {
+	keysURL string
+	client  *http.Client
+	clock   clock.Clock
+
+	mu          sync.Mutex
+	keys        map[string]*ecdsa.PublicKey
+	lastFetched time.Time
+}
+
+// NewVerifier returns a Verifier that loads keys from keysURL.
+func NewVerifier(keysURL string, client *http.Client) *Verifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Verifier{
+		keysURL: keysURL,
+		client:  client,
+		clock:   clock.Real,
+		keys:    map[string]*ecdsa.PublicKey{},
+	}
+}
+
+// WithClock makes v read time from c. It must be called before v is used.
+func (v *Verifier) WithClock(c clock.Clock) *Verifier {
+	v.clock = c
+	return v
+}
+
+// Verify checks that signature is a valid base64 ASN.1 ECDSA signature of
+// body made with the key named by keyID.
+func (v *Verifier) Verify(ctx context.Context, keyID, signature string, body []byte) error {
+	if keyID == "" || signature == "" {
+		return ErrMissingSignature
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	pub, err := v.key(ctx, keyID)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(body)
+	if !ecdsa.VerifyASN1(pub, digest[:], sig) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (v *Verifier) key(ctx context.Context, keyID string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if pub, ok := v.keys[keyID]; ok {
+		return pub, nil
+	}
+	now := v.clock.Now()
+	if !v.lastFetched.IsZero() && now.Sub(v.lastFetched) < refetchInterval {
+		return nil, ErrUnknownKey
+	}
+	// Failed fetches count too, so an outage at GitHub isn't hammered.
+	v.lastFetched = now
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys = keys
+	if pub, ok := v.keys[keyID]; ok {
+		return pub, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*ecdsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.keysURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch secret scanning keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch secret scanning keys: unexpected status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		PublicKeys []struct {
+			KeyIdentifier string `json:"key_identifier"`
+			Key           string `json:"key"`
+		} `json:"public_keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode secret scanning keys: %w", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(payload.PublicKeys))
+	for _, k := range payload.PublicKeys {
+		pub, err := parsePublicKey(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("parse secret scanning key %s: %w", k.KeyIdentifier, err)
+		}
+		keys[k.KeyIdentifier] = pub
+	}
+	return keys, nil
+}
+
+func parsePublicKey(pemKey string) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("not an ECDSA public key")
+	}
+	return pub, nil
+}
@@ -0,0 +1,114 @@
+package secretscan
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+)
+
+func newKeyServer(t *testing.T, keyID string, pub *ecdsa.PublicKey) (*httptest.Server, *int) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+	pemKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"public_keys": []map[string]any{
+				{"key_identifier": keyID, "key": pemKey, "is_current": true},
+			},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func sign(t *testing.T, priv *ecdsa.PrivateKey, body []byte) string {
+	t.Helper()
+	digest := sha256.Sum256(body)
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	if err != nil {
+		t.Fatalf("SignASN1() error = %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	srv, fetches := newKeyServer(t, "key-1", &priv.PublicKey)
+	body := []byte(`[{"token":"sbx_live_abc","type":"sandbox_api_key","url":"https://example.com","source":"content"}]`)
+
+	tests := []struct {
+		name          string
+		keyID         string
+		signature     string
+		body          []byte
+		expectedError error
+	}{
+		{"valid signature", "key-1", sign(t, priv, body), body, nil},
+		{"tampered body", "key-1", sign(t, priv, body), append([]byte(" "), body...), ErrInvalidSignature},
+		{"wrong signing key", "key-1", sign(t, other, body), body, ErrInvalidSignature},
+		{"unknown key identifier", "key-2", sign(t, priv, body), body, ErrUnknownKey},
+		{"signature not base64", "key-1", "not base64!", body, ErrInvalidSignature},
+		{"missing headers", "", "", body, ErrMissingSignature},
+	}
+
+	c := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	v := NewVerifier(srv.URL, srv.Client()).WithClock(c)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Verify(context.Background(), tt.keyID, tt.signature, tt.body)
+			if err != tt.expectedError {
+				t.Errorf("Verify() error = %v, want %v", err, tt.expectedError)
+			}
+		})
+	}
+
+	// key-1 is cached after the first fetch, and the unknown key-2 came too
+	// soon after it to trigger another.
+	if *fetches != 1 {
+		t.Errorf("key endpoint fetched %d times, want 1", *fetches)
+	}
+
+	c.Advance(refetchInterval)
+	if err := v.Verify(context.Background(), "key-2", sign(t, priv, body), body); err != ErrUnknownKey {
+		t.Errorf("Verify() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if err := v.Verify(context.Background(), "key-3", sign(t, priv, body), body); err != ErrUnknownKey {
+		t.Errorf("Verify() error = %v, want %v", err, ErrUnknownKey)
+	}
+	if *fetches != 2 {
+		t.Errorf("key endpoint fetched %d times after refetchInterval, want 2", *fetches)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	got := HashToken("abc")
+	want := "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got != want {
+		t.Errorf("HashToken() = %v, want %v", got, want)
+	}
+}
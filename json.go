@@ -2,8 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/i18n"
 )
 
 func respondWithError(w http.ResponseWriter, code int, msg string, logErr error) {
@@ -21,8 +26,63 @@ func respondWithError(w http.ResponseWriter, code int, msg string, logErr error)
 	})
 }
 
+// Problem types for auth failures. They are stable identifiers: clients
+// match on them, so existing values must never change meaning.
+const (
+	problemInvalidAuthHeader   = "urn:notely:problem:invalid-authorization-header"
+	problemTooManyConcurrent   = "urn:notely:problem:too-many-concurrent-requests"
+	problemKeyStoreUnavailable = "urn:notely:problem:key-store-unavailable"
+	problemUnknownAPIKey       = "urn:notely:problem:unknown-api-key"
+	problemKeyLookupFailed     = "urn:notely:problem:key-lookup-failed"
+	problemRevokedAPIKey       = "urn:notely:problem:revoked-api-key"
+	problemInvalidSignature    = "urn:notely:problem:invalid-signature"
+	problemChallengeRequired   = "urn:notely:problem:challenge-required"
+	problemNotAdmin            = "urn:notely:problem:admin-required"
+	problemInsufficientScope   = "urn:notely:problem:insufficient-scope"
+)
+
+// problemDetails is an RFC 7807 problem document.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// respondWithAuthError writes an auth failure as application/problem+json
+// of the given problem type, with msg translated per the request's
+// Accept-Language. When LegacyErrorResponses is set it writes the legacy
+// untranslated {"error": msg} shape instead.
+func (cfg *apiConfig) respondWithAuthError(w http.ResponseWriter, r *http.Request, code int, problemType, msg string, logErr error) {
+	if cfg.LegacyErrorResponses {
+		respondWithError(w, code, msg, logErr)
+		return
+	}
+	if logErr != nil {
+		log.Println(logErr)
+	}
+	if code > 499 {
+		log.Printf("Responding with 5XX error: %s", msg)
+	}
+	lang := i18n.Negotiate(r.Header.Get("Accept-Language"), authMessageLanguages)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	writeJSON(w, code, problemDetails{
+		Type:   problemType,
+		Title:  http.StatusText(code),
+		Status: code,
+		Detail: authMessages.Message(lang, msg),
+	})
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, code, payload)
+}
+
+// writeJSON writes payload with the Content-Type already set by the caller.
+func writeJSON(w http.ResponseWriter, code int, payload interface{}) {
 	dat, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
@@ -34,3 +94,22 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 		log.Printf("Error writing response: %s", err)
 	}
 }
+
+// decodeJSONBody decodes the request body into v and then reads the body to
+// the end, so that streaming verifiers such as middlewareBodySignature have
+// seen all of it before the handler causes side effects.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return err
+	}
+	_, err := io.Copy(io.Discard, r.Body)
+	return err
+}
+
+func (cfg *apiConfig) respondWithDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, auth.ErrBodySignatureMismatch) {
+		cfg.respondWithAuthError(w, r, http.StatusUnauthorized, problemInvalidSignature, "Body signature mismatch", err)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+}
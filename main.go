@@ -1,25 +1,98 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 
+	"github.com/bootdotdev/learn-cicd-starter/internal/alerting"
+	"github.com/bootdotdev/learn-cicd-starter/internal/anomaly"
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authstats"
+	"github.com/bootdotdev/learn-cicd-starter/internal/batch"
+	"github.com/bootdotdev/learn-cicd-starter/internal/breaker"
+	"github.com/bootdotdev/learn-cicd-starter/internal/challenge"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
 	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+	"github.com/bootdotdev/learn-cicd-starter/internal/deprecation"
+	"github.com/bootdotdev/learn-cicd-starter/internal/idempotency"
+	"github.com/bootdotdev/learn-cicd-starter/internal/limit"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secrets"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secretscan"
 
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
 type apiConfig struct {
-	DB *database.Queries
+	// Clock is the time source for everything time-based in the service.
+	Clock          clock.Clock
+	DB             *database.Queries
+	DBConn         *sql.DB
+	SecretScanning *secretscan.Verifier
+	Honeytokens    auth.Honeytokens
+	Events         *authevents.Bus
+	Concurrency    *limit.Concurrency
+	KeyStore       *breaker.Breaker
+	// Users resolves API keys to their owners through KeyStore, with
+	// caching.
+	Users *auth.Resolver[keyRecord]
+	// KeyTouches batches last-used updates for managed keys off the
+	// request path.
+	KeyTouches *batch.Writer[keyTouch]
+	// FailurePolicies decides how requests are treated while KeyStore
+	// lookups are failing.
+	FailurePolicies auth.FailurePolicies
+	// BodySigningSecret, when set, requires HMAC-signed bodies on writes.
+	BodySigningSecret *secrets.Secret
+	Idempotency       *idempotency.Store
+	// Stats aggregates auth events for the admin API.
+	Stats *authstats.Collector
+	// EventStream relays auth events to operators watching /admin/events.
+	EventStream *authevents.Stream
+	// AdminKey is ADMIN_API_KEY. The admin API is only served when it is
+	// set.
+	AdminKey *secrets.Secret
+	// Alerts, when set, receives auth events and notifies external sinks.
+	Alerts *alerting.Alerter
+	// AlertQueue sends fired alerts off the request path.
+	AlertQueue *alerting.Dispatcher
+	// Anomalies flags keys whose usage departs from their history.
+	Anomalies *anomaly.Detector
+	// Challenges, when set, must be solved by clients ChallengeTracker has
+	// seen failing authentication repeatedly.
+	Challenges       challenge.Provider
+	ChallengeTracker *challenge.Tracker
+	// ShadowParse, when set, compares a candidate header parsing
+	// configuration against the one in use on every authenticated request.
+	ShadowParse *auth.ShadowParse
+	// Deprecations announces the sunset of legacy auth behaviors to
+	// clients using them, linking to DeprecationLink if set.
+	Deprecations    deprecation.Schedule
+	DeprecationLink string
+	// Timeouts bound individual database calls on the auth path.
+	Timeouts operationTimeouts
+	// LegacyErrorResponses restores {"error": msg} bodies for auth failures
+	// in place of problem+json, for clients that can't handle it yet.
+	LegacyErrorResponses bool
+	// AuthDryRun logs every auth decision and serves requests that would
+	// be denied, as long as their key identifies a user. Handlers still
+	// check access to the resources they act on. It is for trying out auth
+	// changes against real traffic.
+	AuthDryRun bool
 }
 
 //go:embed static/*
@@ -31,16 +104,122 @@ func main() {
 		log.Printf("warning: assuming default configuration. .env unreadable: %v", err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		log.Fatal("PORT environment variable is not set")
 	}
 
-	apiCfg := apiConfig{}
+	// ctx ends on SIGINT or SIGTERM, starting a graceful shutdown.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	secretProvider := newSecretProvider()
+
+	apiCfg := apiConfig{
+		Clock:       clock.Real,
+		Honeytokens: auth.ParseHoneytokens(os.Getenv("HONEYTOKEN_FINGERPRINTS")),
+		Anomalies:   anomaly.NewDetector(anomaly.Config{}),
+	}
+	apiCfg.Events = authevents.NewBus().WithClock(apiCfg.Clock)
+	apiCfg.Idempotency = idempotency.NewStore(24*time.Hour, 1000, 100000).WithClock(apiCfg.Clock)
+	apiCfg.Events.Subscribe("log", logAuthEvent)
+	apiCfg.Stats = authstats.NewCollector().WithClock(apiCfg.Clock)
+	apiCfg.Events.Subscribe("stats", apiCfg.Stats.Handle)
+	apiCfg.EventStream = authevents.NewStream()
+	apiCfg.Events.Subscribe("stream", apiCfg.EventStream.Handle)
+
+	apiCfg.Alerts, err = newAlerter(secretProvider)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if apiCfg.Alerts != nil {
+		apiCfg.Alerts.WithClock(apiCfg.Clock)
+		apiCfg.AlertQueue = alerting.NewDispatcher(apiCfg.Alerts, 100, 10*time.Second)
+		apiCfg.Events.SubscribeUnbounded("alerting", apiCfg.alertOnEvent)
+		go apiCfg.watchDroppedEvents(ctx, time.Minute)
+	}
+
+	// Nonces are signed with the secret read here, so rotating it needs a
+	// restart and invalidates outstanding challenges.
+	challengeSecret, err := lookupSecret(secretProvider, "CHALLENGE_SECRET")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if challengeSecret != "" {
+		apiCfg.Challenges = challenge.NewProofOfWork([]byte(challengeSecret), 20, 2*time.Minute).WithClock(apiCfg.Clock)
+		apiCfg.ChallengeTracker = challenge.NewTracker(5, 10*time.Minute).WithClock(apiCfg.Clock)
+		apiCfg.Events.Subscribe("challenge", apiCfg.trackAuthFailure)
+	}
+
+	if v := os.Getenv("LEGACY_ERROR_RESPONSES"); v != "" {
+		apiCfg.LegacyErrorResponses, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("LEGACY_ERROR_RESPONSES is not a boolean: %v", err)
+		}
+	}
+	if v := os.Getenv("AUTH_DRY_RUN"); v != "" {
+		apiCfg.AuthDryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			log.Fatalf("AUTH_DRY_RUN is not a boolean: %v", err)
+		}
+		if apiCfg.AuthDryRun {
+			log.Print("warning: AUTH_DRY_RUN is set; requests auth would deny are served")
+		}
+	}
+
+	apiCfg.Deprecations, err = deprecation.ParseSchedule(os.Getenv("AUTH_DEPRECATION_SCHEDULE"))
+	if err != nil {
+		log.Fatalf("AUTH_DEPRECATION_SCHEDULE: %v", err)
+	}
+	apiCfg.DeprecationLink = os.Getenv("AUTH_DEPRECATION_LINK")
+
+	apiCfg.ShadowParse, err = loadShadowParse()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	apiCfg.Timeouts, err = loadOperationTimeouts()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	maxConcurrent := 10
+	if v := os.Getenv("MAX_CONCURRENT_REQUESTS_PER_KEY"); v != "" {
+		maxConcurrent, err = strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("MAX_CONCURRENT_REQUESTS_PER_KEY is not a number: %v", err)
+		}
+	}
+	apiCfg.Concurrency = limit.NewConcurrency(maxConcurrent)
+
+	defaultPolicy, err := auth.ParseFailurePolicy(os.Getenv("AUTH_FAILURE_POLICY"), auth.FailClosed)
+	if err != nil {
+		log.Fatalf("AUTH_FAILURE_POLICY: %v", err)
+	}
+	apiCfg.FailurePolicies = auth.FailurePolicies{}
+	for class, env := range map[auth.RouteClass]string{
+		auth.RouteClassRead:  "AUTH_FAILURE_POLICY_READ",
+		auth.RouteClassWrite: "AUTH_FAILURE_POLICY_WRITE",
+	} {
+		apiCfg.FailurePolicies[class], err = auth.ParseFailurePolicy(os.Getenv(env), defaultPolicy)
+		if err != nil {
+			log.Fatalf("%s: %v", env, err)
+		}
+	}
 
 	// https://github.com/libsql/libsql-client-go/#open-a-connection-to-sqld
 	// libsql://[your-database].turso.io?authToken=[your-auth-token]
-	dbURL := os.Getenv("DATABASE_URL")
+	dbURL, err := lookupSecret(secretProvider, "DATABASE_URL")
+	if err != nil {
+		log.Fatal(err)
+	}
 	if dbURL == "" {
 		log.Println("DATABASE_URL environment variable is not set")
 		log.Println("Running without CRUD endpoints")
@@ -51,16 +230,93 @@ func main() {
 		}
 		dbQueries := database.New(db)
 		apiCfg.DB = dbQueries
+		apiCfg.DBConn = db
+		apiCfg.KeyStore = breaker.New(5, 30*time.Second, isKeyStoreFailure).WithClock(apiCfg.Clock)
+		// Revocations on other instances take up to the TTL to be seen here.
+		apiCfg.Users = auth.NewResolver(apiCfg.lookupKey, 30*time.Second, 15*time.Minute).WithClock(apiCfg.Clock)
+		apiCfg.KeyTouches = batch.NewWriter("key touches", batch.Config{Overflow: batch.Drop}, apiCfg.writeKeyTouches)
 		log.Println("Connected to database!")
 	}
 
+	secretScanningKeysURL := os.Getenv("SECRET_SCANNING_KEYS_URL")
+	if secretScanningKeysURL == "" {
+		secretScanningKeysURL = secretscan.DefaultKeysURL
+	}
+	apiCfg.SecretScanning = secretscan.NewVerifier(secretScanningKeysURL, &http.Client{Timeout: 10 * time.Second}).WithClock(apiCfg.Clock)
+
+	apiCfg.BodySigningSecret, err = rotatingSecret(secretProvider, "BODY_SIGNING_SECRET", apiCfg.Clock)
+	if err != nil {
+		log.Fatal(err)
+	}
+	apiCfg.AdminKey, err = rotatingSecret(secretProvider, "ADMIN_API_KEY", apiCfg.Clock)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           apiCfg.routes(),
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Serving on port: %s\n", port)
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Println("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	apiCfg.drain(shutdownCtx)
+}
+
+// drain flushes the work queued off the request path once no more requests
+// are being served: key touches first, then auth events, then the alerts
+// those events fire.
+func (cfg *apiConfig) drain(ctx context.Context) {
+	if cfg.KeyTouches != nil {
+		if err := cfg.KeyTouches.Close(ctx); err != nil {
+			log.Printf("Error flushing key touches: %v", err)
+		}
+	}
+	if err := cfg.Events.Close(ctx); err != nil {
+		log.Printf("Error delivering auth events: %v", err)
+	}
+	if cfg.AlertQueue != nil {
+		if err := cfg.AlertQueue.Close(ctx); err != nil {
+			log.Printf("Error sending alerts: %v", err)
+		}
+	}
+	if cfg.DBConn != nil {
+		if err := cfg.DBConn.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
+		}
+	}
+}
+
+// routes returns the service's router. Optional parts are served according
+// to cfg: the CRUD endpoints when DB is set, body signatures when
+// BodySigningSecret is set and the admin API when AdminKey is set.
+func (cfg *apiConfig) routes() chi.Router {
 	router := chi.NewRouter()
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"*"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Deprecation", "Sunset", challenge.Header},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -77,26 +333,111 @@ func main() {
 		}
 	})
 
+	router.Get("/openapi.json", handlerOpenAPI(apiDocument()))
+
 	v1Router := chi.NewRouter()
+	v1Router.Use(cfg.middlewareAuthGuard(router))
 
-	if apiCfg.DB != nil {
-		v1Router.Post("/users", apiCfg.handlerUsersCreate)
-		v1Router.Get("/users", apiCfg.middlewareAuth(apiCfg.handlerUsersGet))
-		v1Router.Get("/notes", apiCfg.middlewareAuth(apiCfg.handlerNotesGet))
-		v1Router.Post("/notes", apiCfg.middlewareAuth(apiCfg.handlerNotesCreate))
+	var signedBody []func(http.Handler) http.Handler
+	if cfg.BodySigningSecret != nil {
+		signedBody = append(signedBody, cfg.middlewareBodySignature)
+	}
+
+	if cfg.DB != nil {
+		v1Router.With(signedBody...).Post("/users", cfg.handlerUsersCreate)
+		v1Router.Get("/users", cfg.middlewareAuth(cfg.handlerUsersGet))
+		v1Router.Delete("/users", cfg.middlewareAuth(cfg.handlerUsersDelete))
+		v1Router.Get("/notes", cfg.middlewareAuth(cfg.handlerNotesGet))
+		v1Router.With(signedBody...).Post("/notes", cfg.middlewareAuth(cfg.middlewareIdempotency(cfg.handlerNotesCreate)))
+		v1Router.Get("/keys", cfg.middlewareAuth(cfg.handlerKeysList))
+		v1Router.With(signedBody...).Post("/keys", cfg.middlewareAuth(cfg.handlerKeysCreate))
+		v1Router.With(signedBody...).Put("/keys/{keyID}", cfg.middlewareAuth(cfg.handlerKeysRename))
+		v1Router.Delete("/keys/{keyID}", cfg.middlewareAuth(cfg.handlerKeysRevoke))
+		v1Router.Post("/secret-scanning", cfg.handlerSecretScanning)
 	}
 
 	v1Router.Get("/healthz", handlerReadiness)
+	v1Router.Get("/healthz/auth", cfg.handlerAuthHealth)
+	v1Router.Get("/readyz", cfg.handlerReadyz)
 
 	router.Mount("/v1", v1Router)
-	srv := &http.Server{
-		Addr:              ":" + port,
-		Handler:           router,
-		ReadTimeout:       10 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		ReadHeaderTimeout: 5 * time.Second,
+
+	if cfg.AdminKey != nil {
+		adminRouter := chi.NewRouter()
+		adminRouter.Get("/stats", cfg.middlewareAdmin(cfg.handlerAdminStats))
+		adminRouter.Get("/events", cfg.middlewareAdmin(cfg.handlerAdminEvents))
+		if cfg.DB != nil {
+			adminRouter.Post("/keys/revoke", cfg.middlewareAdmin(cfg.handlerAdminRevokeKeys))
+			adminRouter.Post("/auth/explain", cfg.middlewareAdmin(handlerAdminExplain(cfg, router)))
+		}
+		router.Mount("/admin", adminRouter)
+	}
+	return router
+}
+
+// timestamp returns the current time in the format stored in the database.
+func (cfg *apiConfig) timestamp() string {
+	return cfg.Clock.Now().UTC().Format(time.RFC3339)
+}
+
+// keyRecord is what an API key resolves to.
+type keyRecord struct {
+	User database.User
+	// KeyID identifies keys created through /v1/keys. It is empty for the
+	// key issued with the user.
+	KeyID   string
+	Revoked bool
+	// Scopes are what the key was granted. The key issued with the user
+	// has every scope.
+	Scopes []string
+}
+
+// lookupKey fetches the record for apiKey, guarded by the KeyStore breaker.
+func (cfg *apiConfig) lookupKey(ctx context.Context, apiKey string) (keyRecord, error) {
+	var rec keyRecord
+	err := cfg.KeyStore.Do(func() error {
+		var err error
+		rec, err = cfg.fetchKey(ctx, apiKey)
+		return err
+	})
+	return rec, err
+}
+
+// fetchKey reads the record for apiKey from the database. The key issued
+// with the user is tried first, then managed keys.
+func (cfg *apiConfig) fetchKey(ctx context.Context, apiKey string) (keyRecord, error) {
+	ctx, cancel := withTimeout(ctx, cfg.Timeouts.KeyLookup)
+	defer cancel()
+
+	user, err := cfg.DB.GetUser(ctx, apiKey)
+	if err == nil {
+		return keyRecord{User: user, Revoked: user.ApiKeyRevokedAt.Valid, Scopes: auth.KnownScopes}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return keyRecord{}, err
+	}
+
+	row, err := cfg.DB.GetUserByAPIKeyHash(ctx, auth.HashKey(apiKey))
+	if err != nil {
+		return keyRecord{}, err
 	}
+	return keyRecord{
+		User: database.User{
+			ID:              row.ID,
+			CreatedAt:       row.CreatedAt,
+			UpdatedAt:       row.UpdatedAt,
+			Name:            row.Name,
+			ApiKey:          row.ApiKey,
+			ApiKeyRevokedAt: row.ApiKeyRevokedAt,
+		},
+		KeyID:   row.KeyID,
+		Revoked: row.KeyRevokedAt.Valid,
+		Scopes:  auth.ParseScopes(row.KeyScopes),
+	}, nil
+}
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(srv.ListenAndServe())
+// isKeyStoreFailure reports whether a lookup error says something about the
+// database's health. Unknown keys and callers hanging up don't.
+func isKeyStoreFailure(err error) bool {
+	return !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, context.Canceled)
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/batch"
+	"github.com/bootdotdev/learn-cicd-starter/internal/breaker"
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+	"github.com/bootdotdev/learn-cicd-starter/internal/idempotency"
+	"github.com/bootdotdev/learn-cicd-starter/internal/limit"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secrets"
+	"github.com/google/uuid"
+)
+
+var testNow = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const testAdminKey = "test-admin-key"
+
+// staticSecrets is a secrets.Provider over a fixed set of values.
+type staticSecrets map[string]string
+
+func (s staticSecrets) Lookup(_ context.Context, name string) ([]byte, error) {
+	v, ok := s[name]
+	if !ok {
+		return nil, secrets.ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+// withAdminAPI serves the admin API, authenticated by testAdminKey.
+func withAdminAPI(cfg *apiConfig) {
+	cfg.AdminKey = secrets.New(staticSecrets{"ADMIN_API_KEY": testAdminKey}, "ADMIN_API_KEY", time.Minute).WithClock(cfg.Clock)
+}
+
+// testServer is the service wired up as main does it, over a fakeDB.
+type testServer struct {
+	cfg     *apiConfig
+	db      *fakeDB
+	clock   *clock.Fake
+	handler http.Handler
+
+	mu     sync.Mutex
+	events []authevents.Payload
+}
+
+// newTestServer returns a testServer. setup, if given, can adjust the
+// configuration before the routes are built.
+func newTestServer(t *testing.T, setup ...func(*apiConfig)) *testServer {
+	t.Helper()
+	fdb, conn := openFakeDB()
+	c := clock.NewFake(testNow)
+
+	cfg := &apiConfig{
+		Clock:       c,
+		DB:          database.New(conn),
+		DBConn:      conn,
+		Honeytokens: auth.Honeytokens{},
+		Concurrency: limit.NewConcurrency(10),
+	}
+	cfg.Events = authevents.NewBus().WithClock(c)
+	s := &testServer{cfg: cfg, db: fdb, clock: c}
+	cfg.Events.Subscribe("test", func(_ context.Context, ev authevents.Event) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.events = append(s.events, ev.Payload)
+		return nil
+	})
+	cfg.KeyStore = breaker.New(5, 30*time.Second, isKeyStoreFailure).WithClock(c)
+	cfg.Users = auth.NewResolver(cfg.lookupKey, 30*time.Second, 15*time.Minute).WithClock(c)
+	cfg.Idempotency = idempotency.NewStore(time.Hour, 100, 1000).WithClock(c)
+	cfg.KeyTouches = batch.NewWriter("key touches", batch.Config{}, cfg.writeKeyTouches)
+	for _, f := range setup {
+		f(cfg)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		cfg.KeyTouches.Close(ctx)
+		cfg.Events.Close(ctx)
+		conn.Close()
+	})
+
+	s.handler = cfg.routes()
+	return s
+}
+
+// published closes the event bus and returns every event published.
+func (s *testServer) published(t *testing.T) []authevents.Payload {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.cfg.Events.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events
+}
+
+// addUser creates a user and returns it. Its ApiKey is the original key.
+func (s *testServer) addUser(t *testing.T, name string) database.User {
+	t.Helper()
+	apiKey, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := testNow.Format(time.RFC3339)
+	user := database.User{ID: uuid.New().String(), CreatedAt: ts, UpdatedAt: ts, Name: name, ApiKey: apiKey}
+	s.db.mu.Lock()
+	s.db.users = append(s.db.users, user)
+	s.db.mu.Unlock()
+	return user
+}
+
+// addKey creates a managed key for userID with scopes and returns it.
+func (s *testServer) addKey(t *testing.T, userID string, scopes ...string) (id, apiKey string) {
+	t.Helper()
+	apiKey, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := testNow.Format(time.RFC3339)
+	id = uuid.New().String()
+	s.db.mu.Lock()
+	s.db.apiKeys = append(s.db.apiKeys, database.ApiKey{
+		ID: id, CreatedAt: ts, UpdatedAt: ts, UserID: userID, Name: "test",
+		KeyHash: auth.HashKey(apiKey), KeyHint: apiKey[len(apiKey)-4:],
+		Scopes: auth.FormatScopes(scopes), CreatedVia: keyCreatedViaAPI,
+	})
+	s.db.mu.Unlock()
+	return id, apiKey
+}
+
+// revoke revokes the managed key id.
+func (s *testServer) revoke(id string) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	for i, k := range s.db.apiKeys {
+		if k.ID == id {
+			s.db.apiKeys[i].RevokedAt = sql.NullString{String: testNow.Format(time.RFC3339), Valid: true}
+		}
+	}
+}
+
+// do sends a request authenticated with apiKey, if set, and with body
+// encoded as JSON, if set.
+func (s *testServer) do(t *testing.T, method, path, apiKey string, body any, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+apiKey)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, req)
+	return w
+}
+
+// decode unmarshals the response body into a map, failing t on error.
+func decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &m); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	return m
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+// middlewareAdmin restricts operator endpoints to callers presenting
+// ADMIN_API_KEY. Keys are compared by hash, in constant time.
+func (cfg *apiConfig) middlewareAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := auth.GetAPIKey(r.Header, auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders))
+		if err != nil {
+			cfg.Events.Publish(authevents.Failure{Reason: err.Error(), RemoteAddr: r.RemoteAddr})
+			cfg.respondWithAuthError(w, r, http.StatusUnauthorized, problemInvalidAuthHeader, "Couldn't find api key", err)
+			return
+		}
+		adminKey, err := cfg.AdminKey.Value(r.Context())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load admin key", err)
+			return
+		}
+		sum, want := sha256.Sum256([]byte(key)), sha256.Sum256(adminKey)
+		if subtle.ConstantTimeCompare(sum[:], want[:]) != 1 {
+			cfg.Events.Publish(authevents.Failure{Reason: "invalid admin key", KeyFingerprint: auth.Fingerprint(key), RemoteAddr: r.RemoteAddr})
+			cfg.respondWithAuthError(w, r, http.StatusForbidden, problemNotAdmin, "Admin access required", nil)
+			return
+		}
+		next(w, r)
+	}
+}
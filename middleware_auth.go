@@ -1,28 +1,115 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"log"
 	"net/http"
 
+	"github.com/google/uuid"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/anomaly"
 	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
 	"github.com/bootdotdev/learn-cicd-starter/internal/database"
 )
 
 type authedHandler func(http.ResponseWriter, *http.Request, database.User)
 
+// userContextKey holds the database.User of an authenticated request, so
+// that it is only authenticated once when middlewareAuth is nested inside
+// middlewareAuthGuard.
+type userContextKey struct{}
+
 func (cfg *apiConfig) middlewareAuth(handler authedHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		apiKey, err := auth.GetAPIKey(r.Header)
-		if err != nil {
-			respondWithError(w, http.StatusUnauthorized, "Couldn't find api key", err)
+		if user, ok := r.Context().Value(userContextKey{}).(database.User); ok {
+			handler(w, r, user)
 			return
 		}
 
-		user, err := cfg.DB.GetUser(r.Context(), apiKey)
-		if err != nil {
-			respondWithError(w, http.StatusNotFound, "Couldn't get user", err)
+		route, ok := r.Context().Value(routePatternKey{}).(string)
+		if !ok {
+			route = r.URL.Path
+		}
+		d := cfg.decideAuth(r, route, true)
+		defer d.Release()
+		if cfg.AuthDryRun {
+			log.Printf("Auth dry run for %s %s from %s: %s", r.Method, r.URL.Path, r.RemoteAddr, d)
+		}
+		if d.Canceled {
+			return
+		}
+		// In a dry run a denied request is still served, as long as its
+		// key identified a user to serve it as.
+		if !d.Allowed && !(cfg.AuthDryRun && d.User.ID != "") {
+			d.respond(cfg, w, r)
+			return
+		}
+		if d.Identity.Attr(auth.AttrHoneytoken) != "" {
+			cfg.handlerDecoy(w, r.WithContext(auth.NewContext(r.Context(), d.Identity)), d.User)
 			return
 		}
 
-		handler(w, r, user)
+		cfg.Events.Publish(authevents.Login{Identity: d.Identity})
+		cfg.observeUsage(r, d.Identity)
+		cfg.signalDeprecations(w, r, d.APIKey, d.Identity)
+		ctx := context.WithValue(auth.NewContext(r.Context(), d.Identity), userContextKey{}, d.User)
+		handler(w, r.WithContext(ctx), d.User)
+	}
+}
+
+// observeUsage feeds the request into the anomaly detector and publishes
+// whatever it flags. Honeytokens are alerted on separately.
+func (cfg *apiConfig) observeUsage(r *http.Request, identity auth.Identity) {
+	if cfg.Anomalies == nil || identity.Attr(auth.AttrHoneytoken) != "" {
+		return
+	}
+	endpoint, ok := r.Context().Value(routePatternKey{}).(string)
+	if !ok {
+		endpoint = r.URL.Path
+	}
+	found := cfg.Anomalies.Observe(anomaly.Usage{
+		Key:      identity.CredentialID,
+		Endpoint: r.Method + " " + endpoint,
+		Addr:     clientAddr(r.RemoteAddr),
+		Time:     cfg.Clock.Now(),
+	})
+	for _, a := range found {
+		cfg.Events.Publish(authevents.Anomaly{KeyFingerprint: a.Key, Kind: string(a.Kind), Detail: a.Detail})
+	}
+}
+
+// userIdentity is the identity of a user authenticated by their API key.
+func userIdentity(user database.User, fingerprint string, scopes []string) auth.Identity {
+	return auth.Identity{
+		ID:           user.ID,
+		Type:         auth.PrincipalUser,
+		Scopes:       scopes,
+		CredentialID: fingerprint,
+	}
+}
+
+// decoyNames are the names honeytoken users go by, so that decoys don't
+// share one.
+var decoyNames = []string{"ci-deploy", "deploy-bot", "release-automation", "build-agent", "staging-deploy", "nightly-backup"}
+
+// honeytokenUser is the decoy identity a honeytoken authenticates as. It has
+// no row in the database, and requests made as it are answered by
+// handlerDecoy rather than the real handlers. Its ID, name and key are
+// derived from fingerprint, so they look like a real user's and stay the
+// same across requests. The key is well-formed but authenticates no one.
+func (cfg *apiConfig) honeytokenUser(fingerprint string) database.User {
+	seed := sha256.Sum256([]byte("honeytoken:" + fingerprint))
+	id, _ := uuid.FromBytes(seed[:16])
+	id[6] = id[6]&0x0f | 0x40 // version 4, like uuid.New
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	now := cfg.timestamp()
+	return database.User{
+		ID:        id.String(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      decoyNames[int(seed[16])%len(decoyNames)],
+		ApiKey:    auth.FormatAPIKey(seed[:]),
 	}
 }
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/anomaly"
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+	"github.com/bootdotdev/learn-cicd-starter/internal/breaker"
+)
+
+func TestObserveUsage_FlagsNewEndpointByRoutePattern(t *testing.T) {
+	s := newTestServer(t, func(cfg *apiConfig) {
+		cfg.Anomalies = anomaly.NewDetector(anomaly.Config{WarmUp: 3})
+	})
+	user := s.addUser(t, "alice")
+	_, key := s.addKey(t, user.ID, auth.ScopeKeysManage)
+
+	for i := 0; i < 3; i++ {
+		s.do(t, http.MethodGet, "/v1/notes", key, nil)
+	}
+	s.do(t, http.MethodPut, "/v1/keys/a", key, map[string]string{"name": "x"})
+	s.do(t, http.MethodPut, "/v1/keys/b", key, map[string]string{"name": "x"})
+
+	var flagged []string
+	for _, p := range s.published(t) {
+		if a, ok := p.(authevents.Anomaly); ok && a.Kind == string(anomaly.KindNewEndpoint) {
+			flagged = append(flagged, a.Detail)
+		}
+	}
+	if len(flagged) != 1 || flagged[0] != "PUT /v1/keys/{keyID}" {
+		t.Errorf("new_endpoint anomalies = %q, want one for PUT /v1/keys/{keyID}", flagged)
+	}
+}
+
+func TestMiddlewareAuth_CanceledLookupIsNotAStoreFailure(t *testing.T) {
+	s := newTestServer(t)
+	user := s.addUser(t, "alice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/v1/notes", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "ApiKey "+user.ApiKey)
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, req)
+
+	if w.Body.Len() != 0 {
+		t.Errorf("response to a canceled request = %d %s, want none", w.Code, w.Body)
+	}
+	for _, p := range s.published(t) {
+		if _, ok := p.(authevents.StoreFallback); ok {
+			t.Errorf("canceled lookup published %+v", p)
+		}
+	}
+	if state := s.cfg.KeyStore.State(); state != breaker.Closed {
+		t.Errorf("breaker = %s, want closed", state)
+	}
+}
+
+func TestMiddlewareAuth_AgreesWithExplain(t *testing.T) {
+	s := newTestServer(t, withAdminAPI)
+	user := s.addUser(t, "alice")
+	_, scopeless := s.addKey(t, user.ID)
+	revokedID, revoked := s.addKey(t, user.ID)
+	s.revoke(revokedID)
+	unknown, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		key          string
+		method, path string
+		failWith     error
+	}{
+		{"allowed", scopeless, http.MethodGet, "/v1/notes", nil},
+		{"no key", "", http.MethodGet, "/v1/notes", nil},
+		{"unknown key", unknown, http.MethodGet, "/v1/notes", nil},
+		{"revoked key", revoked, http.MethodGet, "/v1/notes", nil},
+		{"missing scope", scopeless, http.MethodPost, "/v1/keys", nil},
+		{"store failure", unknown, http.MethodGet, "/v1/notes", errors.New("connection reset")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.db.mu.Lock()
+			s.db.failWith = tt.failWith
+			s.db.mu.Unlock()
+			defer func() {
+				s.db.mu.Lock()
+				s.db.failWith = nil
+				s.db.mu.Unlock()
+			}()
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("Authorization", "ApiKey "+tt.key)
+			}
+			e := s.cfg.explainAuth(req, s.handler.(chi.Routes))
+
+			w := s.do(t, tt.method, tt.path, tt.key, nil)
+			if e.Allowed {
+				if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden || w.Code >= 500 {
+					t.Errorf("explain allowed it, middleware answered %d %s", w.Code, w.Body)
+				}
+				return
+			}
+			if w.Code != e.Status || decode(t, w)["type"] != e.Problem {
+				t.Errorf("explain denied it with %d %s, middleware answered %d %s", e.Status, e.Problem, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestMiddlewareAuth_DryRunServesDeniedRequests(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	s := newTestServer(t, func(cfg *apiConfig) { cfg.AuthDryRun = true })
+	user := s.addUser(t, "alice")
+	id, key := s.addKey(t, user.ID)
+	s.revoke(id)
+
+	if w := s.do(t, http.MethodGet, "/v1/notes", key, nil); w.Code != http.StatusOK {
+		t.Errorf("revoked key: status = %d, want 200; body %s", w.Code, w.Body)
+	}
+	if !strings.Contains(logs.String(), "Auth dry run for GET /v1/notes") || !strings.Contains(logs.String(), "deny 401 "+problemRevokedAPIKey) {
+		t.Errorf("log = %q, want the denial logged", logs.String())
+	}
+
+	// Without a user to serve it as, a denied request is still denied.
+	if w := s.do(t, http.MethodGet, "/v1/notes", "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("no key: status = %d, want 401", w.Code)
+	}
+}
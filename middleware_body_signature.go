@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+)
+
+// middlewareBodySignature requires an HMAC of the request body in
+// auth.BodySignatureHeader. The body is verified as the handler streams it,
+// so handlers must read it with decodeJSONBody, which consumes it to the end
+// before they act on it.
+func (cfg *apiConfig) middlewareBodySignature(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret, err := cfg.BodySigningSecret.Value(r.Context())
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't load body signing secret", err)
+			return
+		}
+		body, err := auth.VerifyBody(r.Body, secret, r.Header.Get(auth.BodySignatureHeader))
+		if err != nil {
+			cfg.respondWithAuthError(w, r, http.StatusUnauthorized, problemInvalidSignature, "Couldn't verify body signature", err)
+			return
+		}
+		r.Body = body
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestMiddlewareAuthGuard_OnlyBypassListIsPublic(t *testing.T) {
+	s := newTestServer(t)
+	routes := map[string]bool{}
+	err := chi.Walk(s.handler.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !strings.HasPrefix(route, "/v1/") {
+			return nil
+		}
+		route = strings.TrimSuffix(route, "/")
+		routes[method+" "+route] = true
+
+		path := strings.ReplaceAll(route, "{keyID}", "some-key")
+		w := s.do(t, method, path, "", nil)
+		// Public handlers may send a 401 of their own, e.g. for a bad
+		// webhook signature, so tell the guard's refusal by its problem.
+		refused := w.Code == http.StatusUnauthorized && strings.Contains(w.Body.String(), problemInvalidAuthHeader)
+		_, public := authBypass[method+" "+route]
+		switch {
+		case public && refused:
+			t.Errorf("%s %s is in authBypass but was refused without a key", method, route)
+		case !public && !refused:
+			t.Errorf("%s %s: status without a key = %d, want 401 %s", method, route, w.Code, problemInvalidAuthHeader)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for route := range authBypass {
+		if !routes[route] {
+			t.Errorf("authBypass lists %s, which isn't a route", route)
+		}
+	}
+}
+
+func TestMiddlewareAuthGuard_UnknownRoutesAreNotFound(t *testing.T) {
+	s := newTestServer(t)
+	if w := s.do(t, http.MethodGet, "/v1/nope", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if w := s.do(t, http.MethodPatch, "/v1/notes", "", nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", w.Code)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+// trackAuthFailure feeds auth failures into ChallengeTracker.
+func (cfg *apiConfig) trackAuthFailure(_ context.Context, ev authevents.Event) error {
+	if f, ok := ev.Payload.(authevents.Failure); ok {
+		cfg.ChallengeTracker.Fail(clientAddr(f.RemoteAddr))
+	}
+	return nil
+}
+
+// clientAddr is the IP part of a request's RemoteAddr.
+func clientAddr(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
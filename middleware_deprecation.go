@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+// Legacy ways of authenticating that still work but are being retired.
+// These names are used in AUTH_DEPRECATION_SCHEDULE and the stats API.
+const (
+	// legacyLenientParsing is an Authorization header only accepted by
+	// auth.ParseLenient, e.g. with trailing data after the key.
+	legacyLenientParsing = "lenient_parsing"
+	// legacyUnprefixedKey is a key issued before keys carried a prefix
+	// and checksum.
+	legacyUnprefixedKey = "unprefixed_key"
+)
+
+// signalDeprecations records which legacy behaviors an authenticated
+// request relied on and, for those with a sunset scheduled, tells the
+// client in the response headers.
+func (cfg *apiConfig) signalDeprecations(w http.ResponseWriter, r *http.Request, apiKey string, identity auth.Identity) {
+	if identity.Attr(auth.AttrHoneytoken) != "" {
+		return
+	}
+
+	used := legacyBehaviors(r, apiKey)
+	for _, behavior := range used {
+		cfg.Events.Publish(authevents.DeprecatedUse{Behavior: behavior, KeyFingerprint: identity.CredentialID})
+	}
+	cfg.Deprecations.Apply(w.Header(), cfg.DeprecationLink, used...)
+}
+
+// legacyBehaviors lists the legacy behaviors r relied on to present apiKey.
+func legacyBehaviors(r *http.Request, apiKey string) []string {
+	var used []string
+	if _, err := auth.GetAPIKey(r.Header, auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders), auth.WithParseMode(auth.ParseStrict)); err != nil {
+		used = append(used, legacyLenientParsing)
+	}
+	if !strings.HasPrefix(apiKey, auth.KeyPrefix) {
+		used = append(used, legacyUnprefixedKey)
+	}
+	return used
+}
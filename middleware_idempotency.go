@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/database"
+	"github.com/bootdotdev/learn-cicd-starter/internal/idempotency"
+)
+
+const (
+	maxIdempotencyKeyLen       = 255
+	maxIdempotentResponseBytes = 1 << 20
+)
+
+// middlewareIdempotency replays the stored response when an authenticated
+// caller retries a request with the same Idempotency-Key. Keys are scoped to
+// the caller's identity. Server errors aren't stored, so those retries run
+// again. A caller holding too many keys gets a 429 until some expire.
+func (cfg *apiConfig) middlewareIdempotency(handler authedHandler) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		clientKey := r.Header.Get(idempotency.Header)
+		if clientKey == "" {
+			handler(w, r, user)
+			return
+		}
+		if len(clientKey) > maxIdempotencyKeyLen {
+			respondWithError(w, http.StatusBadRequest, "Idempotency key too long", nil)
+			return
+		}
+
+		identity, _ := auth.FromContext(r.Context())
+		replay, err := cfg.Idempotency.Begin(identity.ID, clientKey, r.Method+" "+r.URL.Path)
+		switch {
+		case errors.Is(err, idempotency.ErrFull):
+			w.Header().Set("Retry-After", "60")
+			respondWithError(w, http.StatusTooManyRequests, "Too many idempotency keys in use", nil)
+			return
+		case errors.Is(err, idempotency.ErrInProgress):
+			respondWithError(w, http.StatusConflict, "A request with this idempotency key is in progress", nil)
+			return
+		case errors.Is(err, idempotency.ErrKeyReused):
+			respondWithError(w, http.StatusUnprocessableEntity, "Idempotency key was used for a different request", nil)
+			return
+		case replay != nil:
+			if err := idempotency.Replay(w, replay); err != nil {
+				log.Printf("Error writing replayed response: %s", err)
+			}
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				cfg.Idempotency.Abort(identity.ID, clientKey)
+			}
+		}()
+
+		rec := idempotency.NewRecorder(w, maxIdempotentResponseBytes)
+		handler(rec, r, user)
+		if resp, ok := rec.Response(); ok && resp.StatusCode < 500 {
+			cfg.Idempotency.Complete(identity.ID, clientKey, resp)
+			completed = true
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/idempotency"
+)
+
+func TestMiddlewareIdempotency_ReplaysNoteCreation(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.addUser(t, "alice")
+	bob := s.addUser(t, "bob")
+	body := map[string]string{"note": "hello"}
+
+	first := s.do(t, http.MethodPost, "/v1/notes", alice.ApiKey, body, idempotency.Header, "k1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, body %s", first.Code, first.Body)
+	}
+	retry := s.do(t, http.MethodPost, "/v1/notes", alice.ApiKey, body, idempotency.Header, "k1")
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response replayed", retry.Code, retry.Body)
+	}
+	if n := len(s.db.notes); n != 1 {
+		t.Fatalf("notes after retry = %d, want 1", n)
+	}
+
+	// Keys are scoped to the caller.
+	if w := s.do(t, http.MethodPost, "/v1/notes", bob.ApiKey, body, idempotency.Header, "k1"); w.Code != http.StatusCreated || w.Body.String() == first.Body.String() {
+		t.Errorf("another user's request with the same key = %d %s, want a new note", w.Code, w.Body)
+	}
+	if w := s.do(t, http.MethodPost, "/v1/notes", alice.ApiKey, body); w.Code != http.StatusCreated {
+		t.Errorf("without a key: status = %d", w.Code)
+	}
+	if n := len(s.db.notes); n != 3 {
+		t.Errorf("notes = %d, want 3", n)
+	}
+
+	if w := s.do(t, http.MethodPost, "/v1/notes", alice.ApiKey, body, idempotency.Header, strings.Repeat("k", maxIdempotencyKeyLen+1)); w.Code != http.StatusBadRequest {
+		t.Errorf("overlong key: status = %d, want 400", w.Code)
+	}
+}
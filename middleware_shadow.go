@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authevents"
+)
+
+// shadowCheckParse is the ShadowMismatch check for header parsing.
+const shadowCheckParse = "parse"
+
+// loadShadowParse reads AUTH_SHADOW_PARSE_MODE, the parse mode to try in
+// shadow mode ahead of switching to it. It returns nil when unset.
+func loadShadowParse() (*auth.ShadowParse, error) {
+	var mode auth.ParseMode
+	switch v := os.Getenv("AUTH_SHADOW_PARSE_MODE"); v {
+	case "":
+		return nil, nil
+	case "lenient":
+		mode = auth.ParseLenient
+	case "strict":
+		mode = auth.ParseStrict
+	default:
+		return nil, fmt.Errorf("AUTH_SHADOW_PARSE_MODE: unknown parse mode %q", v)
+	}
+	return auth.NewShadowParse(auth.WithMultipleHeaderPolicy(auth.RejectMultipleHeaders), auth.WithParseMode(mode)), nil
+}
+
+// publishShadowMismatch publishes m, a disagreement between the shadow
+// parse configuration and the one in use, which parsed apiKey. The result
+// in use is authoritative either way.
+func (cfg *apiConfig) publishShadowMismatch(r *http.Request, apiKey string, m auth.ParseMismatch) {
+	ev := authevents.ShadowMismatch{
+		Check:      shadowCheckParse,
+		Kind:       m.Kind,
+		Primary:    m.Primary,
+		Candidate:  m.Candidate,
+		RemoteAddr: r.RemoteAddr,
+	}
+	if apiKey != "" {
+		ev.KeyFingerprint = auth.Fingerprint(apiKey)
+	}
+	cfg.Events.Publish(ev)
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"time"
 
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
 	"github.com/bootdotdev/learn-cicd-starter/internal/database"
 )
 
@@ -11,7 +13,7 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Name      string    `json:"name"`
-	ApiKey    string    `json:"api_key"`
+	ApiKey    string    `json:"api_key,omitempty"`
 }
 
 func databaseUserToUser(user database.User) (User, error) {
@@ -72,3 +74,85 @@ func databasePostsToPosts(notes []database.Note) ([]Note, error) {
 	}
 	return result, nil
 }
+
+// APIKey is a managed key as shown to its owner. The key itself is only
+// returned once, when it is created.
+type APIKey struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Name       string     `json:"name"`
+	KeyHint    string     `json:"key_hint"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Scopes     []string   `json:"scopes"`
+	// CreatedVia is the flow that issued the key, and CreatedBy the
+	// fingerprint of the credential that asked for it, if recorded.
+	CreatedVia string  `json:"created_via"`
+	CreatedBy  *string `json:"created_by"`
+	Key        string  `json:"key,omitempty"`
+}
+
+func databaseAPIKeyToAPIKey(key database.ApiKey) (APIKey, error) {
+	createdAt, err := time.Parse(time.RFC3339, key.CreatedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	updatedAt, err := time.Parse(time.RFC3339, key.UpdatedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	lastUsedAt, err := parseNullTime(key.LastUsedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	revokedAt, err := parseNullTime(key.RevokedAt)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return APIKey{
+		ID:         key.ID,
+		CreatedAt:  createdAt,
+		UpdatedAt:  updatedAt,
+		Name:       key.Name,
+		KeyHint:    key.KeyHint,
+		LastUsedAt: lastUsedAt,
+		RevokedAt:  revokedAt,
+		Scopes:     auth.ParseScopes(key.Scopes),
+		CreatedVia: key.CreatedVia,
+		CreatedBy:  nullStringPtr(key.CreatedBy),
+	}, nil
+}
+
+func databaseAPIKeysToAPIKeys(keys []database.ApiKey) ([]APIKey, error) {
+	result := make([]APIKey, len(keys))
+	for i, key := range keys {
+		var err error
+		result[i], err = databaseAPIKeyToAPIKey(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/auth"
+	"github.com/bootdotdev/learn-cicd-starter/internal/authstats"
+	"github.com/bootdotdev/learn-cicd-starter/internal/idempotency"
+	"github.com/bootdotdev/learn-cicd-starter/internal/openapi"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secretscan"
+)
+
+const (
+	apiKeySecurityScheme   = "apiKey"
+	adminKeySecurityScheme = "adminKey"
+)
+
+// apiDocument describes the authentication-related API, including the
+// /admin routes served when ADMIN_API_KEY is set. Schemas are derived from
+// the same types the handlers encode.
+func apiDocument() openapi.Document {
+	type nameParams struct {
+		Name string `json:"name"`
+	}
+	type createKeyParams struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes,omitempty"`
+	}
+	type noteParams struct {
+		Note string `json:"note"`
+	}
+	type errorResponse struct {
+		Error string `json:"error"`
+	}
+	type statusResponse struct {
+		Status string `json:"status"`
+	}
+	type statsResponse struct {
+		ActiveKeys *int64 `json:"active_keys"`
+		authstats.Snapshot
+	}
+	type revokeParams struct {
+		revokeFilter
+		Reason string `json:"reason"`
+		DryRun bool   `json:"dry_run"`
+	}
+	type revokeFailure struct {
+		Error string `json:"error"`
+		revokeSummary
+	}
+	type explainParams struct {
+		Method     string              `json:"method"`
+		Path       string              `json:"path"`
+		Headers    map[string][]string `json:"headers"`
+		RemoteAddr string              `json:"remote_addr"`
+	}
+
+	authed := []openapi.SecurityRequirement{{apiKeySecurityScheme: {}}}
+	admin := []openapi.SecurityRequirement{{adminKeySecurityScheme: {}}}
+	signature := openapi.Parameter{
+		Name:        auth.BodySignatureHeader,
+		In:          "header",
+		Description: "sha256=<hex HMAC of the body>. Required when the server has a body signing secret.",
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+	keyID := openapi.Parameter{Name: "keyID", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	body := func(schema *openapi.Schema) *openapi.RequestBody {
+		return &openapi.RequestBody{Required: true, Content: openapi.JSON(schema)}
+	}
+	ok := func(description string, schema *openapi.Schema) openapi.Response {
+		return openapi.Response{Description: description, Content: openapi.JSON(schema)}
+	}
+	failure := func(description string) openapi.Response {
+		return ok(description, openapi.Ref("Error"))
+	}
+	// Auth failures are problem+json unless LEGACY_ERROR_RESPONSES is set.
+	authFailure := func(description string) openapi.Response {
+		return openapi.Response{
+			Description: description,
+			Content: map[string]openapi.MediaType{
+				"application/problem+json": {Schema: openapi.Ref("Problem")},
+				"application/json":         {Schema: openapi.Ref("Error")},
+			},
+		}
+	}
+	// withAuthFailures adds the failures middlewareAuth can answer any
+	// authenticated route with to responses, keeping route-specific ones.
+	withAuthFailures := func(responses map[string]openapi.Response) map[string]openapi.Response {
+		for code, resp := range map[string]openapi.Response{
+			"401": authFailure("Missing, malformed or revoked API key"),
+			"429": authFailure("Too many concurrent requests with this API key; see Retry-After"),
+			"503": authFailure("The key store is unavailable; see Retry-After"),
+		} {
+			if _, ok := responses[code]; !ok {
+				responses[code] = resp
+			}
+		}
+		return responses
+	}
+	withAdminFailures := func(responses map[string]openapi.Response) map[string]openapi.Response {
+		responses["401"] = authFailure("Missing or malformed API key")
+		responses["403"] = authFailure("Not the admin key")
+		return responses
+	}
+
+	return openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: "Notely API", Version: "1"},
+		Components: openapi.Components{
+			Schemas: map[string]*openapi.Schema{
+				"User":    openapi.SchemaOf(User{}),
+				"Note":    openapi.SchemaOf(Note{}),
+				"APIKey":  openapi.SchemaOf(APIKey{}),
+				"Error":   openapi.SchemaOf(errorResponse{}),
+				"Problem": openapi.SchemaOf(problemDetails{}),
+			},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				apiKeySecurityScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: `"ApiKey <key>"`,
+				},
+				adminKeySecurityScheme: {
+					Type:        "apiKey",
+					In:          "header",
+					Name:        "Authorization",
+					Description: `"ApiKey <ADMIN_API_KEY>". User keys are rejected.`,
+				},
+			},
+		},
+		Paths: map[string]openapi.PathItem{
+			"/v1/users": {
+				"post": {
+					OperationID: "createUser",
+					Summary:     "Create a user and its first API key",
+					Tags:        []string{"users"},
+					Parameters:  []openapi.Parameter{signature},
+					RequestBody: body(openapi.SchemaOf(nameParams{})),
+					Responses: map[string]openapi.Response{
+						"201": ok("The new user, including its API key", openapi.Ref("User")),
+						"401": authFailure("Body signature mismatch"),
+					},
+				},
+				"get": {
+					OperationID: "getUser",
+					Summary:     "Get the authenticated user",
+					Tags:        []string{"users"},
+					Security:    authed,
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"200": ok("The authenticated user. api_key is only included when the caller used it", openapi.Ref("User")),
+					}),
+				},
+				"delete": {
+					OperationID: "eraseUser",
+					Summary:     "Erase the authenticated user and all their data",
+					Tags:        []string{"users"},
+					Security:    authed,
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"200": ok("What was erased", openapi.SchemaOf(erasureReport{})),
+						"403": authFailure("The key lacks account:delete"),
+					}),
+				},
+			},
+			"/v1/notes": {
+				"get": {
+					OperationID: "listNotes",
+					Tags:        []string{"notes"},
+					Security:    authed,
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"200": ok("The user's notes", &openapi.Schema{Type: "array", Items: openapi.Ref("Note")}),
+					}),
+				},
+				"post": {
+					OperationID: "createNote",
+					Tags:        []string{"notes"},
+					Security:    authed,
+					Parameters: []openapi.Parameter{signature, {
+						Name:        idempotency.Header,
+						In:          "header",
+						Description: "Makes retries of this request return the first response instead of creating another note.",
+						Schema:      &openapi.Schema{Type: "string"},
+					}},
+					RequestBody: body(openapi.SchemaOf(noteParams{})),
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"201": ok("The new note", openapi.Ref("Note")),
+						"409": failure("A request with this idempotency key is in progress"),
+						"422": failure("The idempotency key was used for a different request"),
+						"429": authFailure("Too many concurrent requests with this API key, or too many idempotency keys in use; see Retry-After"),
+					}),
+				},
+			},
+			"/v1/keys": {
+				"get": {
+					OperationID: "listKeys",
+					Summary:     "List the authenticated user's managed keys",
+					Tags:        []string{"keys"},
+					Security:    authed,
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"200": ok("The user's keys, without the keys themselves", &openapi.Schema{Type: "array", Items: openapi.Ref("APIKey")}),
+					}),
+				},
+				"post": {
+					OperationID: "createKey",
+					Tags:        []string{"keys"},
+					Security:    authed,
+					Parameters:  []openapi.Parameter{signature},
+					RequestBody: body(openapi.SchemaOf(createKeyParams{})),
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"201": ok("The new key. This is the only response that includes it.", openapi.Ref("APIKey")),
+						"400": failure("Invalid key name or unknown scope"),
+						"403": authFailure("The key lacks keys:manage, or a requested scope"),
+					}),
+				},
+			},
+			"/v1/keys/{keyID}": {
+				"put": {
+					OperationID: "renameKey",
+					Tags:        []string{"keys"},
+					Security:    authed,
+					Parameters:  []openapi.Parameter{keyID, signature},
+					RequestBody: body(openapi.SchemaOf(nameParams{})),
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"200": ok("The renamed key", openapi.Ref("APIKey")),
+						"400": failure("Invalid key name"),
+						"403": authFailure("The key lacks keys:manage"),
+						"404": failure("No such key owned by the user"),
+					}),
+				},
+				"delete": {
+					OperationID: "revokeKey",
+					Tags:        []string{"keys"},
+					Security:    authed,
+					Parameters:  []openapi.Parameter{keyID},
+					Responses: withAuthFailures(map[string]openapi.Response{
+						"204": {Description: "The key is revoked"},
+						"403": authFailure("The key lacks keys:manage"),
+						"404": failure("No such key owned by the user"),
+					}),
+				},
+			},
+			"/v1/secret-scanning": {
+				"post": {
+					OperationID: "reportLeakedKeys",
+					Summary:     "GitHub secret scanning webhook",
+					Tags:        []string{"webhooks"},
+					Parameters: []openapi.Parameter{
+						{Name: secretscan.KeyIdentifierHeader, In: "header", Required: true, Schema: &openapi.Schema{Type: "string"}},
+						{Name: secretscan.SignatureHeader, In: "header", Required: true, Schema: &openapi.Schema{Type: "string"}},
+					},
+					RequestBody: body(openapi.SchemaOf([]secretscan.Match{})),
+					Responses: map[string]openapi.Response{
+						"200": ok("One result per reported token", openapi.SchemaOf([]secretscan.Result{})),
+						"401": authFailure("Report signature invalid"),
+					},
+				},
+			},
+			"/v1/healthz": {
+				"get": {
+					OperationID: "health",
+					Summary:     "Liveness check",
+					Tags:        []string{"health"},
+					Responses: map[string]openapi.Response{
+						"200": ok("The server is up", openapi.SchemaOf(statusResponse{})),
+					},
+				},
+			},
+			"/v1/readyz": {
+				"get": {
+					OperationID: "ready",
+					Summary:     "Readiness check, including a key store lookup",
+					Tags:        []string{"health"},
+					Responses: map[string]openapi.Response{
+						"200": ok("Ready to authenticate requests", openapi.SchemaOf(statusResponse{})),
+						"503": failure("Key store unreachable"),
+					},
+				},
+			},
+			"/v1/healthz/auth": {
+				"get": {
+					OperationID: "authHealth",
+					Tags:        []string{"health"},
+					Responses: map[string]openapi.Response{
+						"200": {Description: "Authentication dependencies are up or degraded"},
+						"503": {Description: "An authentication dependency is down"},
+					},
+				},
+			},
+			"/admin/stats": {
+				"get": {
+					OperationID: "adminStats",
+					Summary:     "Aggregate auth figures for this instance",
+					Tags:        []string{"admin"},
+					Security:    admin,
+					Parameters: []openapi.Parameter{{
+						Name:        "top",
+						In:          "query",
+						Description: "How many of the most used keys to list, 1 to 100.",
+						Schema:      &openapi.Schema{Type: "integer"},
+					}},
+					Responses: withAdminFailures(map[string]openapi.Response{
+						"200": ok("The figures; active_keys is null without a database", openapi.SchemaOf(statsResponse{})),
+						"400": failure("Invalid top"),
+					}),
+				},
+			},
+			"/admin/events": {
+				"get": {
+					OperationID: "adminEvents",
+					Summary:     "Stream auth events as server-sent events",
+					Tags:        []string{"admin"},
+					Security:    admin,
+					Parameters: []openapi.Parameter{
+						{Name: "severity", In: "query", Description: "Minimum severity: info, warning or critical.", Schema: &openapi.Schema{Type: "string"}},
+					},
+					Responses: withAdminFailures(map[string]openapi.Response{
+						"200": {
+							Description: "One event per message, with heartbeats",
+							Content:     map[string]openapi.MediaType{"text/event-stream": {Schema: openapi.SchemaOf(streamedEvent{})}},
+						},
+						"400": failure("Invalid severity, or a tenant filter, which isn't supported"),
+					}),
+				},
+			},
+			"/admin/keys/revoke": {
+				"post": {
+					OperationID: "adminRevokeKeys",
+					Summary:     "Revoke every active key matching a filter",
+					Tags:        []string{"admin"},
+					Security:    admin,
+					RequestBody: body(openapi.SchemaOf(revokeParams{})),
+					Responses: withAdminFailures(map[string]openapi.Response{
+						"200": ok("What was revoked, or would be in a dry run", openapi.SchemaOf(revokeSummary{})),
+						"400": failure("Invalid filter or missing reason"),
+						"500": ok("Revocation stopped part way; keys already revoked stay revoked", openapi.SchemaOf(revokeFailure{})),
+					}),
+				},
+			},
+			"/admin/auth/explain": {
+				"post": {
+					OperationID: "adminExplainAuth",
+					Summary:     "Explain how a captured request would be authenticated",
+					Tags:        []string{"admin"},
+					Security:    admin,
+					RequestBody: body(openapi.SchemaOf(explainParams{})),
+					Responses: withAdminFailures(map[string]openapi.Response{
+						"200": ok("The decision and each step that led to it", openapi.SchemaOf(explanation{})),
+						"400": failure("Missing method or path"),
+					}),
+				},
+			},
+		},
+	}
+}
+
+func handlerOpenAPI(doc openapi.Document) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, doc)
+	}
+}
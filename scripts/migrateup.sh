@@ -4,5 +4,4 @@ if [ -f .env ]; then
     source .env
 fi
 
-cd sql/schema
-goose turso $DATABASE_URL up
+go run . migrate up
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/bootdotdev/learn-cicd-starter/internal/clock"
+	"github.com/bootdotdev/learn-cicd-starter/internal/secrets"
+)
+
+// secretRefresh is how often secrets checked on every request are looked
+// up again, which bounds how long a rotation takes to be picked up.
+const secretRefresh = 5 * time.Minute
+
+// newSecretProvider returns where secrets are read from: files named after
+// them in SECRETS_DIR, if set, and otherwise environment variables.
+func newSecretProvider() secrets.Provider {
+	if dir := os.Getenv("SECRETS_DIR"); dir != "" {
+		return secrets.Chain{secrets.Dir(dir), secrets.Env{}}
+	}
+	return secrets.Env{}
+}
+
+// lookupSecret returns the named secret for one-off use at startup, or ""
+// if it isn't set.
+func lookupSecret(p secrets.Provider, name string) (string, error) {
+	v, err := p.Lookup(context.Background(), name)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return "", nil
+	}
+	return string(v), err
+}
+
+// rotatingSecret returns the named secret, refreshed every secretRefresh,
+// or nil if it isn't set. It is fetched once here so that the features it
+// enables can be decided at startup.
+func rotatingSecret(p secrets.Provider, name string, c clock.Clock) (*secrets.Secret, error) {
+	s := secrets.New(p, name, secretRefresh).WithClock(c)
+	if _, err := s.Value(context.Background()); err != nil {
+		if errors.Is(err, secrets.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,50 @@
+-- name: CountActiveAPIKeys :one
+SELECT (SELECT COUNT(*) FROM users WHERE api_key_revoked_at IS NULL)
+    + (SELECT COUNT(*) FROM api_keys WHERE revoked_at IS NULL) AS active_keys;
+--
+
+-- name: CreateAPIKey :exec
+INSERT INTO api_keys (id, created_at, updated_at, user_id, name, key_hash, key_hint, scopes, created_via, created_by)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+--
+
+-- name: DeleteAPIKeysForUser :execrows
+DELETE FROM api_keys WHERE user_id = ?;
+--
+
+-- name: GetAPIKeyForUser :one
+SELECT * FROM api_keys WHERE id = ? AND user_id = ?;
+--
+
+-- name: ListAPIKeysForUser :many
+SELECT * FROM api_keys WHERE user_id = ? ORDER BY created_at;
+--
+
+-- name: ListActiveAPIKeysPage :many
+SELECT * FROM api_keys
+WHERE revoked_at IS NULL AND id > sqlc.arg(after)
+    AND (sqlc.arg(user_id) = '' OR user_id = sqlc.arg(user_id))
+    AND (sqlc.arg(created_before) = '' OR created_at < sqlc.arg(created_before))
+    AND (sqlc.arg(name) = '' OR name = sqlc.arg(name))
+ORDER BY id LIMIT sqlc.arg(page_size);
+--
+
+-- name: GetUserByAPIKeyHash :one
+SELECT users.id, users.created_at, users.updated_at, users.name, users.api_key, users.api_key_revoked_at,
+    api_keys.id AS key_id, api_keys.revoked_at AS key_revoked_at, api_keys.scopes AS key_scopes
+FROM api_keys JOIN users ON users.id = api_keys.user_id
+WHERE api_keys.key_hash = ?;
+--
+
+-- name: RenameAPIKey :exec
+UPDATE api_keys SET name = ?, updated_at = ? WHERE id = ? AND user_id = ?;
+--
+
+-- name: RevokeManagedAPIKey :execrows
+UPDATE api_keys SET revoked_at = ?, updated_at = ?
+WHERE id = ? AND revoked_at IS NULL;
+--
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys SET last_used_at = ? WHERE id = ?;
+--
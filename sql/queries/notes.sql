@@ -3,6 +3,10 @@ INSERT INTO notes (id, created_at, updated_at, note, user_id)
 VALUES (?, ?, ?, ?, ?);
 --
 
+-- name: DeleteNotesForUser :execrows
+DELETE FROM notes WHERE user_id = ?;
+--
+
 -- name: GetNote :one
 SELECT * FROM notes WHERE id = ?;
 --
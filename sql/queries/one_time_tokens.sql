@@ -0,0 +1,14 @@
+-- name: ConsumeOneTimeToken :one
+UPDATE one_time_tokens SET consumed_at = ?
+WHERE token_hash = ? AND purpose = ? AND consumed_at IS NULL AND expires_at > ?
+RETURNING subject;
+--
+
+-- name: CreateOneTimeToken :exec
+INSERT INTO one_time_tokens (token_hash, purpose, subject, created_at, expires_at)
+VALUES (?, ?, ?, ?, ?);
+--
+
+-- name: DeleteOneTimeTokensForSubject :execrows
+DELETE FROM one_time_tokens WHERE subject = ?;
+--
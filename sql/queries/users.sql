@@ -9,6 +9,23 @@ VALUES (
 );
 --
 
+-- name: DeleteUser :execrows
+DELETE FROM users WHERE id = ?;
+--
+
 -- name: GetUser :one
 SELECT * FROM users WHERE api_key = ?;
 --
+
+-- name: ListActiveUserKeysPage :many
+SELECT * FROM users
+WHERE api_key_revoked_at IS NULL AND id > sqlc.arg(after)
+    AND (sqlc.arg(id) = '' OR id = sqlc.arg(id))
+    AND (sqlc.arg(created_before) = '' OR created_at < sqlc.arg(created_before))
+ORDER BY id LIMIT sqlc.arg(page_size);
+--
+
+-- name: RevokeAPIKey :execrows
+UPDATE users SET api_key_revoked_at = ?, updated_at = ?
+WHERE api_key = ? AND api_key_revoked_at IS NULL;
+--
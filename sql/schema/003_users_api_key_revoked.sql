@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN api_key_revoked_at TEXT;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN api_key_revoked_at;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Existing keys keep the access they had.
+ALTER TABLE api_keys ADD COLUMN scopes TEXT NOT NULL DEFAULT 'keys:manage';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN scopes;
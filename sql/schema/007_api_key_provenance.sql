@@ -0,0 +1,9 @@
+-- +goose Up
+-- Until now keys could only be created through the API, by a credential
+-- that wasn't recorded.
+ALTER TABLE api_keys ADD COLUMN created_via TEXT NOT NULL DEFAULT 'api';
+ALTER TABLE api_keys ADD COLUMN created_by TEXT;
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN created_by;
+ALTER TABLE api_keys DROP COLUMN created_via;
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// operationTimeouts bound each kind of store call, so a slow database
+// fails requests quickly instead of piling up goroutines waiting on it.
+// Calls also end early if the request is cancelled. Zero means no limit
+// beyond the request's own.
+type operationTimeouts struct {
+	// KeyLookup bounds resolving an API key to its owner.
+	KeyLookup time.Duration
+	// KeyWrite bounds revoking keys and recording their use.
+	KeyWrite time.Duration
+}
+
+var defaultOperationTimeouts = operationTimeouts{
+	KeyLookup: 2 * time.Second,
+	KeyWrite:  5 * time.Second,
+}
+
+// loadOperationTimeouts reads KEY_LOOKUP_TIMEOUT and KEY_WRITE_TIMEOUT,
+// e.g. "500ms", falling back to the defaults.
+func loadOperationTimeouts() (operationTimeouts, error) {
+	t := defaultOperationTimeouts
+	for env, d := range map[string]*time.Duration{
+		"KEY_LOOKUP_TIMEOUT": &t.KeyLookup,
+		"KEY_WRITE_TIMEOUT":  &t.KeyWrite,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			return t, fmt.Errorf("%s must be a non-negative duration, got %q", env, v)
+		}
+		*d = parsed
+	}
+	return t, nil
+}
+
+// withTimeout is context.WithTimeout, except that a zero d leaves ctx
+// unbounded.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}